@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/coreos/kscale/apiserver/internal/memstat"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// The benchmarks store b.N objects, so ns/op and allocs/op are per object,
+// and log the live heap the objects retain per object. Run them with a
+// fixed -benchtime, e.g. -benchtime 100000x, to compare stores of the same
+// size with benchstat; -sweep measures the heap at several sizes.
+
+// benchmarkFill times fill storing b.N objects and logs the heap they
+// retain.
+func benchmarkFill(b *testing.B, fill func(count int) error) {
+	b.ReportAllocs()
+	m, err := memstat.Fill(b.N, dropStores, func() error {
+		b.ResetTimer()
+		defer b.StopTimer()
+		return fill(b.N)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Logf("%d objects, %d heap bytes per object", m.Count, m.PerObject())
+}
+
+func benchmarkStore(b *testing.B, newPod func(i int) (*api.Pod, error)) {
+	benchmarkFill(b, func(count int) error { return fillStore(count, newPod) })
+}
+
+func benchmarkCompactStore(b *testing.B, newPod func(i int) (*api.Pod, error)) {
+	benchmarkFill(b, func(count int) error { return fillCompact(count, newPod) })
+}
+
+// the pods of the notes of main.go
+func BenchmarkStoreEmpty(b *testing.B) {
+	benchmarkStore(b, partsPod(false, false, false, false, false))
+}
+
+func BenchmarkStoreTypeMeta(b *testing.B) {
+	benchmarkStore(b, partsPod(true, false, false, false, false))
+}
+
+func BenchmarkStoreObjectMeta(b *testing.B) {
+	benchmarkStore(b, partsPod(false, true, false, false, false))
+}
+
+func BenchmarkStoreObjectMetaNoLabel(b *testing.B) {
+	benchmarkStore(b, partsPod(false, true, true, false, false))
+}
+
+func BenchmarkStorePodSpec(b *testing.B) {
+	benchmarkStore(b, partsPod(false, false, false, true, false))
+}
+
+func BenchmarkStorePodStatus(b *testing.B) {
+	benchmarkStore(b, partsPod(false, false, false, false, true))
+}
+
+func BenchmarkStoreAll(b *testing.B) {
+	benchmarkStore(b, partsPod(true, true, false, true, true))
+}
+
+func BenchmarkCompactStore(b *testing.B) {
+	benchmarkCompactStore(b, partsPod(true, true, false, true, true))
+}
+
+// resourcesPod returns a function that builds pods that set requests and
+// limits.
+func resourcesPod(b *testing.B) func(i int) (*api.Pod, error) {
+	f, err := loadFixture("testdata/pod-resources.json", &api.Pod{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return fixturePod([]fixture{f})
+}
+
+func BenchmarkStoreResources(b *testing.B) {
+	benchmarkStore(b, resourcesPod(b))
+}
+
+func BenchmarkCompactStoreResources(b *testing.B) {
+	benchmarkCompactStore(b, resourcesPod(b))
+}
+
+func benchmarkDecode(b *testing.B, name string) {
+	ds, err := lookupDecoders(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pod, err := partsPod(true, true, false, true, true)(0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	newPod, err := decodedPod(ds[0], []*api.Pod{pod})
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkStore(b, newPod)
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	benchmarkDecode(b, "json")
+}
+
+func BenchmarkDecodeCodec(b *testing.B) {
+	benchmarkDecode(b, "codec")
+}
+
+func BenchmarkDecodeProtobuf(b *testing.B) {
+	benchmarkDecode(b, "protobuf")
+}
+
+// benchmarkKind stores empty objects of a kind, the floor of what the kind
+// costs.
+func benchmarkKind(b *testing.B, name string) {
+	k, err := lookupKind(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkFill(b, func(count int) error {
+		return fillObjects(count, func(int) (interface{}, error) { return k.new(), nil })
+	})
+}
+
+func BenchmarkKindPod(b *testing.B) {
+	benchmarkKind(b, "Pod")
+}
+
+func BenchmarkKindNode(b *testing.B) {
+	benchmarkKind(b, "Node")
+}
+
+func BenchmarkKindEndpoints(b *testing.B) {
+	benchmarkKind(b, "Endpoints")
+}
+
+func BenchmarkKindReplicationController(b *testing.B) {
+	benchmarkKind(b, "ReplicationController")
+}
+
+func BenchmarkKindEvent(b *testing.B) {
+	benchmarkKind(b, "Event")
+}
+
+func BenchmarkKindService(b *testing.B) {
+	benchmarkKind(b, "Service")
+}
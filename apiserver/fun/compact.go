@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/coreos/kscale/apiserver/internal/memstat"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// This prototypes a more compact store of pods, going after the maps the
+// notes of main.go found to take most of the memory of a pod: labels and
+// annotations are kept as sorted slices of interned strings, and equal
+// resource lists of containers share a single map. Pods of a replication
+// controller all ask for the same resources, so the store holds about one
+// pair of resource maps per controller rather than per container.
+// testdata/pod-resources.json is such a pod, e.g.
+//
+//	go run . -compact -fixture testdata/pod-resources.json
+
+// compact is the compact counterpart of store.
+var compact *compactStore
+
+// compactStore holds pods compactly. Its strings are interned across
+// all of its pods.
+type compactStore struct {
+	pods    map[int]*compactPod
+	strings interner
+	// resource lists shared by the pods, by the names of their resources
+	resources map[string][]api.ResourceList
+}
+
+func newCompactStore() *compactStore {
+	return &compactStore{
+		pods:      make(map[int]*compactPod),
+		strings:   make(interner),
+		resources: make(map[string][]api.ResourceList),
+	}
+}
+
+// add stores pod compactly under key i. pod must not be used afterwards,
+// as the compact pod shares its memory.
+func (s *compactStore) add(i int, pod *api.Pod) {
+	c := &compactPod{Pod: *pod}
+	c.labels = s.strings.compactMap(pod.Labels)
+	c.annotations = s.strings.compactMap(pod.Annotations)
+	c.Labels, c.Annotations = nil, nil
+	c.Namespace = s.strings.intern(c.Namespace)
+
+	for j := range c.Spec.Containers {
+		r := &c.Spec.Containers[j].Resources
+		r.Limits = s.resourceList(r.Limits)
+		r.Requests = s.resourceList(r.Requests)
+	}
+	s.pods[i] = c
+}
+
+// resourceList returns a resource list equal to l, shared by all pods of
+// the store. Shared lists must not be modified. A nil list stays nil, as
+// it takes no memory.
+func (s *compactStore) resourceList(l api.ResourceList) api.ResourceList {
+	if l == nil {
+		return nil
+	}
+	var names []string
+	for name := range l {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	key := strings.Join(names, ",")
+
+	for _, shared := range s.resources[key] {
+		if reflect.DeepEqual(shared, l) {
+			return shared
+		}
+	}
+	s.resources[key] = append(s.resources[key], l)
+	return l
+}
+
+// compactPod is a pod whose labels and annotations are kept as compact
+// maps instead of Go maps.
+type compactPod struct {
+	// with nil labels and annotations
+	api.Pod
+	labels, annotations compactMap
+}
+
+// pod returns the pod c holds, as a new pod with Go maps.
+func (c *compactPod) pod() *api.Pod {
+	pod := c.Pod
+	pod.Labels = c.labels.toMap()
+	pod.Annotations = c.annotations.toMap()
+	return &pod
+}
+
+// compactMap is a map of strings as a slice sorted by key. A nil
+// compactMap is a nil map.
+type compactMap []compactEntry
+
+type compactEntry struct {
+	key, value string
+}
+
+// get returns the value of key, like a map index.
+func (m compactMap) get(key string) (string, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].key >= key })
+	if i < len(m) && m[i].key == key {
+		return m[i].value, true
+	}
+	return "", false
+}
+
+func (m compactMap) toMap() map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for _, e := range m {
+		out[e.key] = e.value
+	}
+	return out
+}
+
+type byKey compactMap
+
+func (m byKey) Len() int           { return len(m) }
+func (m byKey) Less(i, j int) bool { return m[i].key < m[j].key }
+func (m byKey) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// interner dedups strings.
+type interner map[string]string
+
+func (in interner) intern(s string) string {
+	if t, ok := in[s]; ok {
+		return t
+	}
+	in[s] = s
+	return s
+}
+
+// compactMap returns m as a compact map of interned strings.
+func (in interner) compactMap(m map[string]string) compactMap {
+	if m == nil {
+		return nil
+	}
+	c := make(compactMap, 0, len(m))
+	for k, v := range m {
+		c = append(c, compactEntry{in.intern(k), in.intern(v)})
+	}
+	sort.Sort(byKey(c))
+	return c
+}
+
+// measureCompact fills the compact store with count pods built by newPod
+// and measures the heap they take, including the interned strings. It
+// first checks that compacting the first pod loses nothing.
+func measureCompact(count int, newPod func(i int) (*api.Pod, error)) (memstat.Measurement, error) {
+	if count > 0 {
+		pod, err := newPod(0)
+		if err != nil {
+			return memstat.Measurement{}, err
+		}
+		want, err := newPod(0)
+		if err != nil {
+			return memstat.Measurement{}, err
+		}
+		s := newCompactStore()
+		s.add(0, pod)
+		if got := s.pods[0].pod(); !reflect.DeepEqual(got, want) {
+			return memstat.Measurement{}, fmt.Errorf("compact pod differs from its pod:\n%#v\nwant\n%#v", got, want)
+		}
+	}
+
+	return memstat.Fill(count, dropStores, func() error { return fillCompact(count, newPod) })
+}
+
+// fillCompact replaces the compact store with count pods built by newPod.
+func fillCompact(count int, newPod func(i int) (*api.Pod, error)) error {
+	compact = newCompactStore()
+	for i := 0; i < count; i++ {
+		pod, err := newPod(i)
+		if err != nil {
+			return err
+		}
+		compact.add(i, pod)
+	}
+	return nil
+}
+
+// compareCompact measures count pods in the plain and in the compact
+// store, side by side.
+func compareCompact(counts []int, newPod func(i int) (*api.Pod, error)) error {
+	fmt.Printf("%10s %16s %16s %10s\n", "pods", "plain per pod", "compact per pod", "saved")
+	for _, count := range counts {
+		plain, err := measure(count, newPod)
+		if err != nil {
+			return err
+		}
+		c, err := measureCompact(count, newPod)
+		if err != nil {
+			return err
+		}
+		saved := 0.0
+		if plain.Heap() > 0 {
+			saved = 100 * float64(plain.Heap()-c.Heap()) / float64(plain.Heap())
+		}
+		fmt.Printf("%10d %16d %16d %9.1f%%\n", count, plain.PerObject(), c.PerObject(), saved)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	_ "k8s.io/kubernetes/pkg/api/install"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// decoder decodes pods the way some part of Kubernetes does, e.g. the
+// apiserver reading them from etcd.
+type decoder struct {
+	name string
+	// encode returns the encoding of pod that decode reads
+	encode func(pod *api.Pod) ([]byte, error)
+	decode func(data []byte) (*api.Pod, error)
+}
+
+var decoders = []decoder{
+	// what the rest of this tool does
+	{"json", encodeV1JSON, decodeJSON},
+	// the apiserver's path: v1 JSON through the codec, defaulted and
+	// converted to the internal version
+	{"codec", encodeV1JSON, decodeCodec},
+	// v1 protobuf, as etcd3 may store pods, converted to the internal
+	// version
+	{"protobuf", encodeProtobuf, decodeProtobuf},
+}
+
+// lookupDecoders returns the decoders of a comma separated list of names,
+// or all of them for "all".
+func lookupDecoders(names string) ([]decoder, error) {
+	if names == "all" {
+		return decoders, nil
+	}
+	var ds []decoder
+next:
+	for _, name := range strings.Split(names, ",") {
+		for _, d := range decoders {
+			if d.name == name {
+				ds = append(ds, d)
+				continue next
+			}
+		}
+		var known []string
+		for _, d := range decoders {
+			known = append(known, d.name)
+		}
+		return nil, fmt.Errorf("unknown decoder %q, known decoders: %s", name, strings.Join(known, ", "))
+	}
+	return ds, nil
+}
+
+func encodeV1JSON(pod *api.Pod) ([]byte, error) {
+	return runtime.Encode(api.Codecs.LegacyCodec(v1.SchemeGroupVersion), pod)
+}
+
+func decodeJSON(data []byte) (*api.Pod, error) {
+	pod := &api.Pod{}
+	if err := json.Unmarshal(data, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+func decodeCodec(data []byte) (*api.Pod, error) {
+	obj, err := runtime.Decode(api.Codecs.UniversalDecoder(), data)
+	if err != nil {
+		return nil, err
+	}
+	pod, ok := obj.(*api.Pod)
+	if !ok {
+		return nil, fmt.Errorf("decoded %T, want *api.Pod", obj)
+	}
+	return pod, nil
+}
+
+func encodeProtobuf(pod *api.Pod) ([]byte, error) {
+	var vpod v1.Pod
+	if err := api.Scheme.Convert(pod, &vpod); err != nil {
+		return nil, err
+	}
+	return vpod.Marshal()
+}
+
+func decodeProtobuf(data []byte) (*api.Pod, error) {
+	var vpod v1.Pod
+	if err := vpod.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	pod := &api.Pod{}
+	if err := api.Scheme.Convert(&vpod, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// decodedPod returns a function that decodes the ith pod of samples with
+// d, after encoding every sample up front.
+func decodedPod(d decoder, samples []*api.Pod) (func(i int) (*api.Pod, error), error) {
+	var encoded [][]byte
+	for _, pod := range samples {
+		data, err := d.encode(pod)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot encode pod: %v", d.name, err)
+		}
+		encoded = append(encoded, data)
+	}
+	return func(i int) (*api.Pod, error) {
+		pod, err := d.decode(encoded[i%len(encoded)])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", d.name, err)
+		}
+		return pod, nil
+	}, nil
+}
+
+// compareDecoders stores count pods decoded by each decoder in turn and
+// prints the heap they retain, the allocations and the time taken to
+// decode them.
+func compareDecoders(ds []decoder, samples []*api.Pod, counts []int) error {
+	fmt.Printf("%-10s %10s %14s %14s %14s\n", "decoder", "pods", "bytes per pod", "allocs per pod", "ns per pod")
+	for _, d := range ds {
+		newPod, err := decodedPod(d, samples)
+		if err != nil {
+			return err
+		}
+		for _, count := range counts {
+			m, err := measure(count, newPod)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-10s %10d %14d %14d %14d\n", d.name, m.Count, m.PerObject(), m.AllocsPerObject(), m.NsPerObject())
+		}
+	}
+	return nil
+}
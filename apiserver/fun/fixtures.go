@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/yaml"
+)
+
+// fixture is the JSON of an object to fill the store with.
+type fixture struct {
+	name string
+	json []byte
+}
+
+// loadFixture reads a JSON or YAML fixture of an object from a file. The
+// fixture is decoded into obj once up front, so that a bad fixture fails
+// before any measurement.
+func loadFixture(path string, obj interface{}) (fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixture{}, err
+	}
+	data, err = yaml.ToJSON(data)
+	if err != nil {
+		return fixture{}, fmt.Errorf("%s: %v", path, err)
+	}
+	f := fixture{name: path, json: data}
+	return f, f.decode(obj)
+}
+
+// decode decodes f into obj.
+func (f fixture) decode(obj interface{}) error {
+	if err := json.Unmarshal(f.json, obj); err != nil {
+		return fmt.Errorf("%s: %v", f.name, err)
+	}
+	return nil
+}
+
+// newPod decodes a new pod from f.
+func (f fixture) newPod() (*api.Pod, error) {
+	pod := &api.Pod{}
+	if err := f.decode(pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// fixtureFlags collects the fixtures of repeated -fixture flags.
+type fixtureFlags []fixture
+
+func (fs *fixtureFlags) String() string {
+	var names []string
+	for _, f := range *fs {
+		names = append(names, f.name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (fs *fixtureFlags) Set(path string) error {
+	f, err := loadFixture(path, &api.Pod{})
+	if err != nil {
+		return err
+	}
+	*fs = append(*fs, f)
+	return nil
+}
+
+// countFlags is a comma separated list of object counts.
+type countFlags []int
+
+func (cs *countFlags) String() string {
+	var ss []string
+	for _, c := range *cs {
+		ss = append(ss, strconv.Itoa(c))
+	}
+	return strings.Join(ss, ",")
+}
+
+func (cs *countFlags) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || c < 0 {
+			return fmt.Errorf("bad object count %q", s)
+		}
+		*cs = append(*cs, c)
+	}
+	return nil
+}
+
+// the built-in fixtures, which are parts of a pod of the density test
+var (
+	omjson = []byte(`{
+      "name":"scale-rc-9-zumj1",
+      "generateName":"scale-rc-9-",
+      "namespace":"scale-ns-1",
+      "uid":"1a332dc8-341f-11e6-84a3-42010af0000e",
+      "creationTimestamp":"2016-06-17T00:04:46Z",
+      "labels":{
+         "name":"scale-label-1-9"
+      },
+      "annotations":{
+         "kubernetes.io/created-by":"{\"kind\":\"SerializedReference\",\"apiVersion\":\"v1\",\"reference\":{\"kind\":\"ReplicationController\",\"namespace\":\"scale-ns-1\",\"name\":\"scale-rc-9\",\"uid\":\"e40ad334-341e-11e6-84a3-42010af0000e\",\"apiVersion\":\"v1\",\"resourceVersion\":\"379108\"}}\n"
+      }
+   }
+   `)
+
+	omjsonnol = []byte(`{
+      "name":"scale-rc-9-zumj1",
+      "generateName":"scale-rc-9-",
+      "namespace":"scale-ns-1",
+      "uid":"1a332dc8-341f-11e6-84a3-42010af0000e",
+      "creationTimestamp":"2016-06-17T00:04:46Z",
+      "annotations":{
+         "kubernetes.io/created-by":"{\"kind\":\"SerializedReference\",\"apiVersion\":\"v1\",\"reference\":{\"kind\":\"ReplicationController\",\"namespace\":\"scale-ns-1\",\"name\":\"scale-rc-9\",\"uid\":\"e40ad334-341e-11e6-84a3-42010af0000e\",\"apiVersion\":\"v1\",\"resourceVersion\":\"379108\"}}\n"
+      }
+   }
+   `)
+
+	podspecjson = []byte(`{
+      "containers":[
+         {
+            "name":"none",
+            "image":"none",
+            "resources":{},
+            "terminationMessagePath":"/dev/termination-log",
+            "imagePullPolicy":"Always"
+         }
+      ],
+      "restartPolicy":"Always",
+      "terminationGracePeriodSeconds":30,
+      "dnsPolicy":"ClusterFirst",
+      "securityContext":{}
+   }`)
+
+	statusjson = []byte(`{
+        "status":{
+        "phase":"Pending"
+      }
+	}`)
+)
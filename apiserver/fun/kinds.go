@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/kscale/apiserver/internal/memstat"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// Besides pods, the apiserver and controllers hold other kinds of objects
+// in their caches. This measures them the same way as pods, and adds them
+// up for the composition of a target cluster.
+
+// objects holds objects of any kind, like store holds pods.
+var objects map[int]interface{}
+
+// kind is an API kind whose objects can be measured.
+type kind struct {
+	name string
+	// new returns a pointer to a new internal object of the kind
+	new func() interface{}
+}
+
+var kinds = []kind{
+	{"Pod", func() interface{} { return &api.Pod{} }},
+	{"Node", func() interface{} { return &api.Node{} }},
+	{"Endpoints", func() interface{} { return &api.Endpoints{} }},
+	{"ReplicationController", func() interface{} { return &api.ReplicationController{} }},
+	{"Event", func() interface{} { return &api.Event{} }},
+	{"Service", func() interface{} { return &api.Service{} }},
+}
+
+// lookupKind returns the kind of a name, ignoring case.
+func lookupKind(name string) (kind, error) {
+	var names []string
+	for _, k := range kinds {
+		if strings.EqualFold(k.name, name) {
+			return k, nil
+		}
+		names = append(names, k.name)
+	}
+	return kind{}, fmt.Errorf("unknown kind %q, known kinds: %s", name, strings.Join(names, ", "))
+}
+
+// kindFixture is a fixture of an object of a kind.
+type kindFixture struct {
+	kind    kind
+	fixture fixture
+}
+
+// objectFlags collects the fixtures of repeated -object flags of the form
+// Kind=path.
+type objectFlags []kindFixture
+
+func (fs *objectFlags) String() string {
+	var ss []string
+	for _, f := range *fs {
+		ss = append(ss, f.kind.name+"="+f.fixture.name)
+	}
+	return strings.Join(ss, ",")
+}
+
+func (fs *objectFlags) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i == -1 {
+		return fmt.Errorf("want Kind=path, got %q", v)
+	}
+	k, err := lookupKind(v[:i])
+	if err != nil {
+		return err
+	}
+	f, err := loadFixture(v[i+1:], k.new())
+	if err != nil {
+		return err
+	}
+	*fs = append(*fs, kindFixture{k, f})
+	return nil
+}
+
+// compositionFlag is the number of objects of each kind in a cluster, of
+// the form Kind=count,...
+type compositionFlag map[string]int
+
+func (c compositionFlag) String() string {
+	var ss []string
+	for name, n := range c {
+		ss = append(ss, name+"="+strconv.Itoa(n))
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+func (c compositionFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		i := strings.Index(s, "=")
+		if i == -1 {
+			return fmt.Errorf("want Kind=count, got %q", s)
+		}
+		k, err := lookupKind(strings.TrimSpace(s[:i]))
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		if err != nil || n < 0 {
+			return fmt.Errorf("bad count of %s: %q", k.name, s[i+1:])
+		}
+		c[k.name] = n
+	}
+	return nil
+}
+
+// measureObjects fills the object store with count objects built by
+// newObject and measures the heap they take.
+func measureObjects(count int, newObject func(i int) (interface{}, error)) (memstat.Measurement, error) {
+	return memstat.Fill(count, dropStores, func() error { return fillObjects(count, newObject) })
+}
+
+// fillObjects replaces the object store with count objects built by
+// newObject.
+func fillObjects(count int, newObject func(i int) (interface{}, error)) error {
+	objects = make(map[int]interface{})
+	for i := 0; i < count; i++ {
+		obj, err := newObject(i)
+		if err != nil {
+			return err
+		}
+		objects[i] = obj
+	}
+	return nil
+}
+
+// compareKinds measures count objects of every kind with fixtures, and
+// prints their cost per object and in total for the numbers of objects of
+// composition. Kinds of composition without fixtures are pods built by
+// newPod, or else an error, and so are kinds with fixtures but not in
+// composition. An empty composition counts count objects of every kind
+// with fixtures.
+func compareKinds(count int, fixtures []kindFixture, composition map[string]int,
+	newPod func(i int) (*api.Pod, error)) error {
+	byKind := make(map[string][]fixture)
+	for _, f := range fixtures {
+		byKind[f.kind.name] = append(byKind[f.kind.name], f.fixture)
+	}
+	if len(composition) == 0 {
+		composition = make(map[string]int)
+		for name := range byKind {
+			composition[name] = count
+		}
+	}
+	for _, f := range fixtures {
+		if _, ok := composition[f.kind.name]; !ok {
+			return fmt.Errorf("-object %s is not in the composition, add %s=count to -composition",
+				f.kind.name, f.kind.name)
+		}
+	}
+
+	// objects of every kind of the composition, in the order of kinds
+	type measured struct {
+		kind      kind
+		count     int
+		newObject func(i int) (interface{}, error)
+	}
+	var ms []measured
+	for _, k := range kinds {
+		n, ok := composition[k.name]
+		if !ok {
+			continue
+		}
+		k, fs := k, byKind[k.name]
+		m := measured{kind: k, count: n}
+		switch {
+		case len(fs) > 0:
+			m.newObject = func(i int) (interface{}, error) {
+				obj := k.new()
+				return obj, fs[i%len(fs)].decode(obj)
+			}
+		case k.name == "Pod":
+			m.newObject = func(i int) (interface{}, error) { return newPod(i) }
+		default:
+			return fmt.Errorf("no fixture of %s, give one with -object %s=path", k.name, k.name)
+		}
+		ms = append(ms, m)
+	}
+
+	fmt.Printf("%-22s %16s %12s %16s\n", "kind", "bytes per object", "objects", "total bytes")
+	var total int64
+	for _, m := range ms {
+		mm, err := measureObjects(count, m.newObject)
+		if err != nil {
+			return err
+		}
+		cost := mm.PerObject() * int64(m.count)
+		total += cost
+		fmt.Printf("%-22s %16d %12d %16d\n", m.kind.name, mm.PerObject(), m.count, cost)
+	}
+	fmt.Printf("%-22s %16s %12s %16d\n", "total", "", "", total)
+	return nil
+}
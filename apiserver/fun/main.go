@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"runtime"
+	"os"
 
+	"github.com/coreos/kscale/apiserver/internal/memstat"
 	"k8s.io/kubernetes/pkg/api"
 )
 
@@ -59,6 +60,12 @@ var store map[int]*api.Pod
 // OK. No significant change as expected.
 
 // In conclusion: all the additional memory are took by the map structs in pod.
+//
+// -breakdown repeats this analysis for any pod: it estimates the heap
+// of every field with reflection, see sizeof.go.
+//
+// go test -bench . runs the measurements as benchmarks, per object, to
+// track with benchstat, see bench_test.go.
 
 func main() {
 	var (
@@ -67,6 +74,20 @@ func main() {
 		omNoLabel bool
 		pspec     bool
 		ps        bool
+
+		fixtures fixtureFlags
+		n        int
+		sweep    countFlags
+
+		breakdown    bool
+		breakdownAll bool
+
+		decode string
+
+		compacted bool
+
+		kindFixtures objectFlags
+		composition  = compositionFlag{}
 	)
 	flag.BoolVar(&tm, "typemeta", false, "fill in typemeta")
 	flag.BoolVar(&om, "objectmeta", false, "fill in objectmeta")
@@ -75,10 +96,112 @@ func main() {
 
 	flag.BoolVar(&ps, "podstatus", false, "fill in podstatus")
 
+	flag.Var(&fixtures, "fixture", "JSON or YAML file of a whole pod to store instead of the parts above; "+
+		"repeat to store a mix of pods, cycling through the fixtures")
+	flag.IntVar(&n, "n", 100000, "number of pods, or objects of each kind, to store")
+	flag.Var(&sweep, "sweep", "comma separated numbers of pods to measure one after another, e.g. 1000,10000,100000; overrides -n")
+
+	flag.BoolVar(&breakdown, "breakdown", false, "print the estimated heap of a pod by field instead of measuring")
+	flag.BoolVar(&breakdownAll, "breakdown-all", false, "with -breakdown, also print fields that take no heap")
+
+	flag.StringVar(&decode, "decode", "", "compare the pods decoded by these comma separated decoders, "+
+		"or all: json, codec (v1 JSON through the apiserver codec), protobuf (v1 protobuf converted to internal)")
+
+	flag.BoolVar(&compacted, "compact", false, "compare the plain store against a compact store of the same pods")
+
+	flag.Var(&kindFixtures, "object", "Kind=path of a JSON or YAML fixture of an object to measure; kinds are "+
+		"Pod, Node, Endpoints, ReplicationController, Event and Service; repeat for several kinds or a mix of one kind")
+	flag.Var(composition, "composition", "comma separated Kind=count of a target cluster to total the cost of "+
+		"the objects measured with -object, e.g. Pod=100000,Node=1000; pods without -object are built as above")
+
 	flag.Parse()
 
-	store = make(map[int]*api.Pod)
-	for i := 0; i < 100000; i++ {
+	newPod := partsPod(tm, om, omNoLabel, pspec, ps)
+	if len(fixtures) > 0 {
+		newPod = fixturePod(fixtures)
+	}
+
+	counts := []int(sweep)
+	if len(counts) == 0 {
+		counts = []int{n}
+	}
+
+	if breakdown || breakdownAll {
+		pod, err := newPod(0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printBreakdown(os.Stdout, pod, breakdownAll)
+		return
+	}
+
+	if decode != "" {
+		ds, err := lookupDecoders(decode)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		samples := make([]*api.Pod, len(fixtures))
+		if len(samples) == 0 {
+			samples = make([]*api.Pod, 1)
+		}
+		for i := range samples {
+			if samples[i], err = newPod(i); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		if err := compareDecoders(ds, samples, counts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(kindFixtures) > 0 || len(composition) > 0 {
+		if err := compareKinds(n, kindFixtures, composition, newPod); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if compacted {
+		if err := compareCompact(counts, newPod); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(sweep) == 0 {
+		m, err := measure(n, newPod)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		st := m.After
+		fmt.Printf("alloc: %d, sys: %d, idle: %d, inuse: %d\n", st.HeapAlloc, st.HeapSys, st.HeapIdle, st.HeapInuse)
+		fmt.Printf("%d pods, %d bytes per pod\n", m.Count, m.PerObject())
+		return
+	}
+
+	fmt.Printf("%10s %14s %14s\n", "pods", "heap bytes", "bytes per pod")
+	for _, count := range sweep {
+		m, err := measure(count, newPod)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%10d %14d %14d\n", m.Count, m.Heap(), m.PerObject())
+	}
+}
+
+// partsPod returns a function that builds pods from the chosen parts of
+// the built-in fixtures.
+func partsPod(tm, om, omNoLabel, pspec, ps bool) func(i int) (*api.Pod, error) {
+	return func(i int) (*api.Pod, error) {
 		pod := &api.Pod{}
 		if tm {
 			pod.TypeMeta.Kind = "Pod"
@@ -92,76 +215,51 @@ func main() {
 				data = omjson
 			}
 			if err := json.Unmarshal(data, &pod.ObjectMeta); err != nil {
-				panic(err)
+				return nil, err
 			}
 		}
 		if pspec {
 			if err := json.Unmarshal(podspecjson, &pod.Spec); err != nil {
-				panic(err)
+				return nil, err
 			}
 		}
 		if ps {
 			if err := json.Unmarshal(statusjson, &pod.Status); err != nil {
-				panic(err)
+				return nil, err
 			}
 		}
-		store[i] = pod
+		return pod, nil
 	}
+}
 
-	runtime.GC()
+// fixturePod returns a function that decodes the ith pod from the
+// fixtures in turn.
+func fixturePod(fs []fixture) func(i int) (*api.Pod, error) {
+	return func(i int) (*api.Pod, error) {
+		return fs[i%len(fs)].newPod()
+	}
+}
 
-	var st runtime.MemStats
-	runtime.ReadMemStats(&st)
-	fmt.Printf("alloc: %d, sys: %d, idle: %d, inuse: %d\n", st.HeapAlloc, st.HeapSys, st.HeapIdle, st.HeapInuse)
+// measure fills the store with count pods built by newPod and measures
+// the heap they take. The previous store is dropped first.
+func measure(count int, newPod func(i int) (*api.Pod, error)) (memstat.Measurement, error) {
+	return memstat.Fill(count, dropStores, func() error { return fillStore(count, newPod) })
 }
 
-var (
-	omjson = []byte(`{
-      "name":"scale-rc-9-zumj1",
-      "generateName":"scale-rc-9-",
-      "namespace":"scale-ns-1",
-      "uid":"1a332dc8-341f-11e6-84a3-42010af0000e",
-      "creationTimestamp":"2016-06-17T00:04:46Z",
-      "labels":{
-         "name":"scale-label-1-9"
-      },
-      "annotations":{
-         "kubernetes.io/created-by":"{\"kind\":\"SerializedReference\",\"apiVersion\":\"v1\",\"reference\":{\"kind\":\"ReplicationController\",\"namespace\":\"scale-ns-1\",\"name\":\"scale-rc-9\",\"uid\":\"e40ad334-341e-11e6-84a3-42010af0000e\",\"apiVersion\":\"v1\",\"resourceVersion\":\"379108\"}}\n"
-      }
-   }
-   `)
-
-	omjsonnol = []byte(`{
-      "name":"scale-rc-9-zumj1",
-      "generateName":"scale-rc-9-",
-      "namespace":"scale-ns-1",
-      "uid":"1a332dc8-341f-11e6-84a3-42010af0000e",
-      "creationTimestamp":"2016-06-17T00:04:46Z",
-      "annotations":{
-         "kubernetes.io/created-by":"{\"kind\":\"SerializedReference\",\"apiVersion\":\"v1\",\"reference\":{\"kind\":\"ReplicationController\",\"namespace\":\"scale-ns-1\",\"name\":\"scale-rc-9\",\"uid\":\"e40ad334-341e-11e6-84a3-42010af0000e\",\"apiVersion\":\"v1\",\"resourceVersion\":\"379108\"}}\n"
-      }
-   }
-   `)
-
-	podspecjson = []byte(`{
-      "containers":[
-         {
-            "name":"none",
-            "image":"none",
-            "resources":{},
-            "terminationMessagePath":"/dev/termination-log",
-            "imagePullPolicy":"Always"
-         }
-      ],
-      "restartPolicy":"Always",
-      "terminationGracePeriodSeconds":30,
-      "dnsPolicy":"ClusterFirst",
-      "securityContext":{}
-   }`)
-
-	statusjson = []byte(`{
-        "status":{
-        "phase":"Pending"
-      }
-	}`)
-)
+// fillStore replaces the store with count pods built by newPod.
+func fillStore(count int, newPod func(i int) (*api.Pod, error)) error {
+	store = make(map[int]*api.Pod)
+	for i := 0; i < count; i++ {
+		pod, err := newPod(i)
+		if err != nil {
+			return err
+		}
+		store[i] = pod
+	}
+	return nil
+}
+
+// dropStores lets go of the objects of all stores, before a measurement.
+func dropStores() {
+	store, compact, objects = nil, nil, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// This automates the investigation in the notes of main.go: it walks an
+// object with reflection and estimates the heap each of its fields takes,
+// assuming a 64-bit platform and Go's allocator and map layout.
+
+// sizeClasses are the object sizes Go's allocator rounds small
+// allocations up to.
+var sizeClasses = []int64{
+	8, 16, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240, 256,
+	288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896, 1024,
+	1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200, 3456, 4096,
+	4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240, 10880, 12288,
+	13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576, 27264, 28672, 32768,
+}
+
+const pageSize = 8192
+
+// allocSize returns the bytes the allocator takes for an allocation of n
+// bytes.
+func allocSize(n int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	i := sort.Search(len(sizeClasses), func(i int) bool { return sizeClasses[i] >= n })
+	if i < len(sizeClasses) {
+		return sizeClasses[i]
+	}
+	return (n + pageSize - 1) / pageSize * pageSize
+}
+
+// map layout of the Go runtime
+const (
+	hmapSize      = 48
+	bucketCnt     = 8
+	loadFactor    = 6.5
+	maxInlineKV   = 128
+	pointerSize   = 8
+	bucketTophash = bucketCnt
+)
+
+// mapSize estimates the header and buckets of a map of n entries of the
+// given type.
+func mapSize(t reflect.Type, n int) int64 {
+	size := allocSize(hmapSize)
+	if n == 0 {
+		return size
+	}
+	b := uint(0)
+	for n > bucketCnt && float64(n) > loadFactor*float64(int64(1)<<b) {
+		b++
+	}
+	ks, vs := t.Key().Size(), t.Elem().Size()
+	if ks > maxInlineKV {
+		ks = pointerSize
+	}
+	if vs > maxInlineKV {
+		vs = pointerSize
+	}
+	bucket := int64(bucketTophash) + bucketCnt*int64(ks) + bucketCnt*int64(vs) + pointerSize
+	return size + allocSize(bucket<<b)
+}
+
+// fieldCost is the estimated memory of a field of an object, summed over
+// all elements for fields of slice, array and map elements.
+type fieldCost struct {
+	path string
+	// bytes the field takes inside its parent
+	inline int64
+	// bytes allocated for the field itself, e.g. the bytes of a string or
+	// the buckets of a map
+	self int64
+	// self plus the self of everything under the field
+	total int64
+}
+
+// sizer estimates the heap an object takes, by field.
+type sizer struct {
+	fields map[string]*fieldCost
+	// pointers already counted
+	seen map[uintptr]bool
+}
+
+// sizeOf estimates the heap the object pointed to by obj takes, by
+// field. Fields are named by their path from the type of the object, with
+// [] for the elements of slices and arrays and {k} and {v} for the keys
+// and values of maps.
+func sizeOf(obj interface{}) []*fieldCost {
+	s := &sizer{fields: make(map[string]*fieldCost), seen: make(map[uintptr]bool)}
+	v := reflect.ValueOf(obj)
+	root := v.Type().String()
+	if v.Kind() == reflect.Ptr {
+		root = v.Type().Elem().Name()
+	}
+	s.walk(v, []string{root})
+
+	var fs []*fieldCost
+	for _, f := range s.fields {
+		fs = append(fs, f)
+	}
+	sort.Sort(byTotal(fs))
+	return fs
+}
+
+func (s *sizer) field(path []string) *fieldCost {
+	p := strings.Join(path, "")
+	f, ok := s.fields[p]
+	if !ok {
+		f = &fieldCost{path: p}
+		s.fields[p] = f
+	}
+	return f
+}
+
+// alloc attributes an allocation of n bytes to the field at path.
+func (s *sizer) alloc(path []string, n int64) {
+	s.field(path).self += n
+	for i := 1; i <= len(path); i++ {
+		s.field(path[:i]).total += n
+	}
+}
+
+var locationType = reflect.TypeOf(time.Location{})
+
+func (s *sizer) walk(v reflect.Value, path []string) {
+	switch v.Kind() {
+	case reflect.String:
+		s.alloc(path, allocSize(int64(v.Len())))
+
+	case reflect.Ptr:
+		// time zones are shared, mostly static data
+		if v.IsNil() || v.Type().Elem() == locationType || s.seen[v.Pointer()] {
+			return
+		}
+		s.seen[v.Pointer()] = true
+		s.alloc(path, allocSize(int64(v.Type().Elem().Size())))
+		s.walk(v.Elem(), path)
+
+	case reflect.Slice:
+		if v.IsNil() || s.seen[v.Pointer()] {
+			return
+		}
+		s.seen[v.Pointer()] = true
+		s.alloc(path, allocSize(int64(v.Cap())*int64(v.Type().Elem().Size())))
+		s.walkElems(v, path)
+
+	case reflect.Array:
+		s.walkElems(v, path)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		s.alloc(path, mapSize(v.Type(), v.Len()))
+		kpath := append(path[:len(path):len(path)], "{k}")
+		vpath := append(path[:len(path):len(path)], "{v}")
+		for _, k := range v.MapKeys() {
+			s.walk(k, kpath)
+			s.walk(v.MapIndex(k), vpath)
+		}
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		switch e.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		default:
+			// non-pointer values are boxed
+			s.alloc(path, allocSize(int64(e.Type().Size())))
+		}
+		s.walk(e, path)
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			fpath := append(path[:len(path):len(path)], "."+f.Name)
+			s.field(fpath).inline += int64(f.Type.Size())
+			s.walk(v.Field(i), fpath)
+		}
+	}
+}
+
+func (s *sizer) walkElems(v reflect.Value, path []string) {
+	epath := append(path[:len(path):len(path)], "[]")
+	for i := 0; i < v.Len(); i++ {
+		s.walk(v.Index(i), epath)
+	}
+}
+
+type byTotal []*fieldCost
+
+func (fs byTotal) Len() int      { return len(fs) }
+func (fs byTotal) Swap(i, j int) { fs[i], fs[j] = fs[j], fs[i] }
+func (fs byTotal) Less(i, j int) bool {
+	if fs[i].total != fs[j].total {
+		return fs[i].total > fs[j].total
+	}
+	return fs[i].path < fs[j].path
+}
+
+// printBreakdown prints the estimated heap of obj by field, largest first.
+// Fields that take no heap of their own or below them are left out unless
+// all is set.
+func printBreakdown(w io.Writer, obj interface{}, all bool) {
+	fs := sizeOf(obj)
+	if len(fs) > 0 {
+		fmt.Fprintf(w, "estimated heap of %s: %d bytes\n", fs[0].path, fs[0].total)
+	}
+	fmt.Fprintf(w, "%10s %10s %10s  %s\n", "total", "self", "inline", "field")
+	for _, f := range fs {
+		if f.total == 0 && !all {
+			continue
+		}
+		fmt.Fprintf(w, "%10d %10d %10d  %s\n", f.total, f.self, f.inline, f.path)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/kscale/apiserver/internal/memstat"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/client/cache"
+	controllerframework "k8s.io/kubernetes/pkg/controller/framework"
+	kruntime "k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/types"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+// The controller harness measures the memory of a controller against a live
+// apiserver. This measures the pod informer of a controller offline: it
+// feeds synthetic pods through a fake watch into a shared informer, with
+// and without indexers, and measures the heap the cache takes per pod and
+// per index.
+
+var (
+	// pods holds the pods alone, to tell the cost of the cache from the
+	// cost of the pods it holds
+	pods []*api.Pod
+	// informer is the informer being measured, and stop stops it
+	informer controllerframework.SharedIndexInformer
+	stop     func()
+)
+
+// podIndexers are the indexers that can be measured, by name.
+var podIndexers = []struct {
+	name string
+	fn   cache.IndexFunc
+}{
+	{cache.NamespaceIndex, cache.MetaNamespaceIndexFunc},
+	// what the scheduler and kubelets look pods up by
+	{"node", func(obj interface{}) ([]string, error) {
+		return []string{obj.(*api.Pod).Spec.NodeName}, nil
+	}},
+	// what the replication manager looks pods up by
+	{"label", func(obj interface{}) ([]string, error) {
+		return []string{obj.(*api.Pod).Labels["name"]}, nil
+	}},
+}
+
+func lookupIndexers(names string) (cache.Indexers, error) {
+	is := cache.Indexers{}
+	if names == "" {
+		return is, nil
+	}
+next:
+	for _, name := range strings.Split(names, ",") {
+		for _, i := range podIndexers {
+			if i.name == name {
+				is[name] = i.fn
+				continue next
+			}
+		}
+		var known []string
+		for _, i := range podIndexers {
+			known = append(known, i.name)
+		}
+		return nil, fmt.Errorf("unknown indexer %q, known indexers: %s", name, strings.Join(known, ", "))
+	}
+	return is, nil
+}
+
+// podSource makes synthetic pods like those of the density test, spread
+// over namespaces, replication controllers and nodes.
+type podSource struct {
+	namespaces int
+	rcs        int
+	nodes      int
+}
+
+func (s podSource) newPod(i int) *api.Pod {
+	ns := i % s.namespaces
+	rc := i / s.namespaces % s.rcs
+	node := i % s.nodes
+	gracePeriod := int64(30)
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name:              fmt.Sprintf("scale-rc-%d-%d", rc, i),
+			GenerateName:      fmt.Sprintf("scale-rc-%d-", rc),
+			Namespace:         fmt.Sprintf("scale-ns-%d", ns),
+			UID:               types.UID(fmt.Sprintf("%08x-0000-4000-8000-%012x", i, i)),
+			ResourceVersion:   strconv.Itoa(i + 1),
+			CreationTimestamp: unversioned.Now(),
+			Labels:            map[string]string{"name": fmt.Sprintf("scale-label-%d-%d", ns, rc)},
+			Annotations: map[string]string{
+				"kubernetes.io/created-by": fmt.Sprintf(`{"kind":"SerializedReference","apiVersion":"v1",`+
+					`"reference":{"kind":"ReplicationController","namespace":"scale-ns-%d","name":"scale-rc-%d","apiVersion":"v1"}}`, ns, rc),
+			},
+		},
+		Spec: api.PodSpec{
+			Containers: []api.Container{{
+				Name:                   "none",
+				Image:                  "none",
+				TerminationMessagePath: "/dev/termination-log",
+				ImagePullPolicy:        api.PullAlways,
+			}},
+			RestartPolicy:                 api.RestartPolicyAlways,
+			TerminationGracePeriodSeconds: &gracePeriod,
+			DNSPolicy:                     api.DNSClusterFirst,
+			NodeName:                      fmt.Sprintf("node-%d", node),
+			SecurityContext:               &api.PodSecurityContext{},
+		},
+		Status: api.PodStatus{
+			Phase:  api.PodRunning,
+			HostIP: fmt.Sprintf("10.0.%d.%d", node/256, node%256),
+			PodIP:  fmt.Sprintf("10.1.%d.%d", i/256%256, i%256),
+		},
+	}
+}
+
+func main() {
+	var (
+		n       int
+		src     podSource
+		indexes string
+	)
+	flag.IntVar(&n, "n", 100000, "number of pods to feed the informer")
+	flag.IntVar(&src.namespaces, "namespaces", 100, "number of namespaces to spread the pods over")
+	flag.IntVar(&src.rcs, "rcs", 10, "number of replication controllers per namespace, each labeling its pods")
+	flag.IntVar(&src.nodes, "nodes", 1000, "number of nodes to spread the pods over")
+	flag.StringVar(&indexes, "indexers", "namespace,node,label",
+		"comma separated indexers to measure one at a time and all together: namespace, node, label")
+	flag.Parse()
+
+	if src.namespaces < 1 || src.rcs < 1 || src.nodes < 1 {
+		fmt.Fprintln(os.Stderr, "-namespaces, -rcs and -nodes must be at least 1")
+		os.Exit(1)
+	}
+	indexers, err := lookupIndexers(indexes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := compareInformers(n, src, indexers); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// compareInformers measures n pods alone, in an informer without indexers,
+// in an informer with each of indexers and with all of them. It prints the
+// heap per pod, the part of it the cache takes beyond the pods, and the
+// part each index takes beyond the informer without indexers.
+func compareInformers(n int, src podSource, indexers cache.Indexers) error {
+	plain, err := measurePods(n, src)
+	if err != nil {
+		return err
+	}
+	bare, err := measureInformer(n, src, cache.Indexers{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-32s %10s %16s %16s %16s %14s\n",
+		"store", "pods", "bytes per pod", "cache per pod", "index per pod", "ns per pod")
+	row := func(name string, m memstat.Measurement, cachePer, indexPer string) {
+		fmt.Printf("%-32s %10d %16d %16s %16s %14d\n", name, m.Count, m.PerObject(), cachePer, indexPer, m.NsPerObject())
+	}
+	over := func(m, base memstat.Measurement) string {
+		if m.Count == 0 {
+			return "0"
+		}
+		return strconv.FormatInt((m.Heap()-base.Heap())/int64(m.Count), 10)
+	}
+	row("pods", plain, "-", "-")
+	row("informer", bare, over(bare, plain), "-")
+
+	// each index alone, in the order of podIndexers, then all of them
+	var configs []cache.Indexers
+	var names []string
+	for _, i := range podIndexers {
+		if fn, ok := indexers[i.name]; ok {
+			configs = append(configs, cache.Indexers{i.name: fn})
+			names = append(names, i.name)
+		}
+	}
+	if len(indexers) > 1 {
+		configs = append(configs, indexers)
+		names = append(names, strings.Join(names, "+"))
+	}
+	for i, is := range configs {
+		m, err := measureInformer(n, src, is)
+		if err != nil {
+			return err
+		}
+		row("informer+"+names[i], m, over(m, plain), over(m, bare))
+	}
+	return nil
+}
+
+// measurePods stores count pods in a slice and measures the heap they take.
+func measurePods(count int, src podSource) (memstat.Measurement, error) {
+	return memstat.Fill(count, dropInformer, func() error {
+		pods = make([]*api.Pod, count)
+		for i := range pods {
+			pods[i] = src.newPod(i)
+		}
+		return nil
+	})
+}
+
+// podWatch is the watch the pods are fed through. Unlike watch.FakeWatcher,
+// whose Add blocks until the informer reads the pod and panics once the
+// watch is stopped, add gives up when the watch is stopped.
+type podWatch struct {
+	result  chan watch.Event
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func newPodWatch() *podWatch {
+	return &podWatch{result: make(chan watch.Event), stopped: make(chan struct{})}
+}
+
+func (w *podWatch) ResultChan() <-chan watch.Event { return w.result }
+
+func (w *podWatch) Stop() { w.once.Do(func() { close(w.stopped) }) }
+
+// add sends pod to the informer, and reports false once the watch is
+// stopped.
+func (w *podWatch) add(pod *api.Pod) bool {
+	select {
+	case w.result <- watch.Event{Type: watch.Added, Object: pod}:
+		return true
+	case <-w.stopped:
+		return false
+	}
+}
+
+// measureInformer feeds count pods through a fake watch into a shared
+// informer with indexers and measures the heap the informer takes once it
+// has them all. The informer keeps running until the next measurement.
+func measureInformer(count int, src podSource, indexers cache.Indexers) (memstat.Measurement, error) {
+	return memstat.Fill(count, dropInformer, func() error {
+		w := newPodWatch()
+		watched := false
+		lw := &cache.ListWatch{
+			// nothing to list, the pods all come through the watch
+			ListFunc: func(options api.ListOptions) (kruntime.Object, error) {
+				return &api.PodList{ListMeta: unversioned.ListMeta{ResourceVersion: "0"}}, nil
+			},
+			WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
+				// a rewatch sees no more pods
+				if watched {
+					return watch.NewFake(), nil
+				}
+				watched = true
+				return w, nil
+			},
+		}
+		informer = controllerframework.NewSharedIndexInformer(lw, &api.Pod{}, 0, indexers)
+
+		done := make(chan struct{})
+		added := 0
+		err := informer.AddEventHandler(controllerframework.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				added++
+				if added == count {
+					close(done)
+				}
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		stopCh := make(chan struct{})
+		ran := make(chan struct{})
+		fed := make(chan struct{})
+		stop = func() {
+			close(stopCh)
+			w.Stop()
+			// wait for the informer to let go of its pods, and for the feed
+			// to give up on them
+			<-ran
+			<-fed
+		}
+		go func() {
+			informer.Run(stopCh)
+			close(ran)
+		}()
+
+		if count == 0 {
+			close(fed)
+			return nil
+		}
+		go func() {
+			defer close(fed)
+			for i := 0; i < count; i++ {
+				if !w.add(src.newPod(i)) {
+					return
+				}
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Minute):
+			got := len(informer.GetStore().ListKeys())
+			stop()
+			stop = nil
+			return fmt.Errorf("informer got %d of %d pods", got, count)
+		}
+		if got := len(informer.GetStore().ListKeys()); got != count {
+			return fmt.Errorf("informer cached %d of %d pods", got, count)
+		}
+		return nil
+	})
+}
+
+// dropInformer stops the informer of the previous measurement and lets go
+// of its pods, and of the pods measured alone.
+func dropInformer() {
+	if stop != nil {
+		stop()
+	}
+	pods, informer, stop = nil, nil, nil
+}
@@ -0,0 +1,67 @@
+// Package memstat measures the live heap objects take once stored, and the
+// time it takes to store them.
+package memstat
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Measurement is the heap before and after storing Count objects, and the
+// time it took to store them.
+type Measurement struct {
+	Count         int
+	Before, After runtime.MemStats
+	Elapsed       time.Duration
+}
+
+// Heap returns the bytes of live heap taken by the objects.
+func (m Measurement) Heap() int64 {
+	return int64(m.After.HeapAlloc) - int64(m.Before.HeapAlloc)
+}
+
+func (m Measurement) PerObject() int64 {
+	if m.Count == 0 {
+		return 0
+	}
+	return m.Heap() / int64(m.Count)
+}
+
+// AllocsPerObject returns the heap allocations made per object, including
+// those of garbage.
+func (m Measurement) AllocsPerObject() int64 {
+	if m.Count == 0 {
+		return 0
+	}
+	return int64(m.After.Mallocs-m.Before.Mallocs) / int64(m.Count)
+}
+
+func (m Measurement) NsPerObject() int64 {
+	if m.Count == 0 {
+		return 0
+	}
+	return m.Elapsed.Nanoseconds() / int64(m.Count)
+}
+
+// Fill measures the heap taken by the count objects fill stores. drop is
+// called first to let go of the objects of previous measurements, so that
+// they are collected before the heap is read.
+func Fill(count int, drop func(), fill func() error) (Measurement, error) {
+	m := Measurement{Count: count}
+
+	drop()
+	runtime.GC()
+	debug.FreeOSMemory()
+	runtime.ReadMemStats(&m.Before)
+
+	start := time.Now()
+	if err := fill(); err != nil {
+		return m, err
+	}
+	m.Elapsed = time.Since(start)
+
+	runtime.GC()
+	runtime.ReadMemStats(&m.After)
+	return m, nil
+}
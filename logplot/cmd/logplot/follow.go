@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/kscale/logplot"
+)
+
+// how often to check a followed log for new lines
+const followPollInterval = 500 * time.Millisecond
+
+// follow tails the log at path like `tail -F`, parsing lines as they are
+// appended and calling report on all records parsed so far every refresh. The
+// log is reopened from the start if it is truncated or replaced. follow
+// reports one last time and returns on interrupt.
+func follow(lt logplot.LogType, opts logplot.Options, path string, refresh time.Duration,
+	report func([]logplot.Record) error) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	t := &tailer{path: path}
+	defer t.close()
+
+	p := lt.NewParser(opts)
+	var rs []logplot.Record
+	reported, lineNum, skipped := 0, 0, 0
+
+	update := func() {
+		if len(rs) == reported {
+			return
+		}
+		if err := report(rs); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+		}
+		reported = len(rs)
+	}
+
+	poll := time.NewTicker(followPollInterval)
+	defer poll.Stop()
+	tick := time.NewTicker(refresh)
+	defer tick.Stop()
+	for {
+		select {
+		case <-poll.C:
+			lines, reset, err := t.readLines()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", path, err)
+				continue
+			}
+			if reset {
+				fmt.Fprintf(opts.Log, "%s was truncated or replaced, starting over\n", path)
+				p, rs, reported, lineNum, skipped = lt.NewParser(opts), nil, 0, 0, 0
+			}
+			for _, line := range lines {
+				lineNum++
+				line = strings.TrimSpace(line)
+				rec, ok, err := p.ParseLine(line)
+				switch {
+				case err != nil && opts.Lenient:
+					skipped++
+					fmt.Fprintf(os.Stderr, "WARNING: skipped malformed line (%d so far): %v\n",
+						skipped, &logplot.ParseError{Line: lineNum, Text: line, Err: err})
+				case err != nil:
+					update()
+					fmt.Fprintf(os.Stderr, "Failed to parse %s: %v\n", path,
+						&logplot.ParseError{Line: lineNum, Text: line, Err: err})
+					os.Exit(1)
+				case ok:
+					rs = append(rs, rec)
+				}
+			}
+		case <-tick.C:
+			update()
+		case <-interrupt:
+			update()
+			return
+		}
+	}
+}
+
+// tailer reads the lines appended to a file, reopening the file if it is
+// truncated or replaced.
+type tailer struct {
+	path string
+
+	f    *os.File
+	br   *bufio.Reader
+	off  int64
+	part string // unterminated last line
+}
+
+// readLines returns the complete lines appended since the last call. reset
+// is true if the file was truncated or replaced and lines come from its
+// start. A missing file is not an error; it is waited for.
+func (t *tailer) readLines() (lines []string, reset bool, err error) {
+	fi, err := os.Stat(t.path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if t.f != nil {
+		cur, err := t.f.Stat()
+		if err != nil {
+			return nil, false, err
+		}
+		if !os.SameFile(fi, cur) || fi.Size() < t.off {
+			t.close()
+			reset = true
+		}
+	}
+	if t.f == nil {
+		if t.f, err = os.Open(t.path); err != nil {
+			return nil, reset, err
+		}
+		t.br = bufio.NewReader(t.f)
+		t.off = 0
+		t.part = ""
+	}
+
+	for {
+		s, err := t.br.ReadString('\n')
+		t.off += int64(len(s))
+		if err == io.EOF {
+			t.part += s
+			return lines, reset, nil
+		}
+		if err != nil {
+			return lines, reset, err
+		}
+		lines = append(lines, t.part+s[:len(s)-1])
+		t.part = ""
+	}
+}
+
+func (t *tailer) close() {
+	if t.f != nil {
+		t.f.Close()
+		t.f = nil
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>logplot: {{.Path}}</title>
+</head>
+<body>
+<h1>{{.Path}}</h1>
+<p>Reloads every {{.Refresh}} seconds.</p>
+{{range .Images}}<div><img src="files/{{.}}" alt="{{.}}"></div>
+{{end}}</body>
+</html>
+`))
+
+// serveOutput serves the output directory on addr, along with an index
+// page that shows the latest graphs and reloads itself every refresh. It
+// reports the address it serves on to log.
+func serveOutput(addr string, out logplot.Output, path string, refresh time.Duration, log io.Writer) {
+	mux := http.NewServeMux()
+	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(out.Dir))))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		images, err := filepath.Glob(out.ImagePath("*"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range images {
+			images[i] = filepath.Base(images[i])
+		}
+		indexTemplate.Execute(w, struct {
+			Path    string
+			Refresh int
+			Images  []string
+		}{path, int(refresh.Seconds()) + 1, images})
+	})
+
+	go func() {
+		fmt.Fprintln(os.Stderr, http.ListenAndServe(addr, mux))
+		os.Exit(1)
+	}()
+	fmt.Fprintf(log, "serving graphs on http://%s/\n", addr)
+}
@@ -0,0 +1,224 @@
+// Command logplot plots and summarizes the logs of Kubernetes scale tests.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/kscale/logplot"
+	"github.com/coreos/kscale/logplot/regression"
+	"github.com/gonum/plot/vg"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "trend" {
+		trend(os.Args[2:])
+		return
+	}
+
+	opts := logplot.DefaultOptions()
+	opts.Log = os.Stdout
+
+	var files runFlags
+	flag.Var(&files, "f", "data file path as [label=]path, where path may be - for stdin, a .gz file "+
+		"or a tar archive member as archive.tar.gz:member; repeat to compare runs (default data.txt)")
+	dtype := flag.String("t", "density", "data type: "+strings.Join(logplot.Names(), ", "))
+	flag.DurationVar(&opts.FallbackInterval, "interval", opts.FallbackInterval,
+		"interval between density lines, used for lines without a timestamp")
+	flag.BoolVar(&opts.AllPhases, "all-phases", false,
+		"also plot inactive, terminating, unknown and runningButNotReady pods")
+	flag.StringVar(&opts.Output.Dir, "o", opts.Output.Dir, "output directory")
+	flag.StringVar(&opts.Output.Prefix, "prefix", opts.Output.Prefix, "prefix of output file names")
+	flag.StringVar(&opts.Output.Format, "format", opts.Output.Format,
+		"image format of graphs: "+strings.Join(logplot.ImageFormats, ", "))
+	width := flag.Float64("width", 10, "width of graphs in inches")
+	height := flag.Float64("height", 10, "height of graphs in inches")
+	var baseline, candidate logplot.Globs
+	flag.Var(&baseline, "baseline", "glob of baseline runs, which may match tar archive members as archive.tar.gz:pattern; "+
+		"with -candidate, checks for regressions instead of plotting")
+	flag.Var(&candidate, "candidate", "glob of candidate runs to check against -baseline")
+	var regCfg regression.Config
+	flag.Float64Var(&regCfg.Threshold, "threshold", 0.05, "relative change by which the candidate must be worse to regress")
+	flag.Float64Var(&regCfg.Alpha, "alpha", 0.05, "significance level of regressions across repeated runs")
+	flag.StringVar(&opts.Smoothing.Method, "smooth", "",
+		"smoothing of rate plots: ma (moving average), ewma, or pNN (moving NNth percentile, e.g. p50)")
+	flag.IntVar(&opts.Smoothing.Window, "window", 5, "number of points averaged by -smooth ma or pNN")
+	flag.Float64Var(&opts.Smoothing.Alpha, "ewma-weight", 0.3, "weight of the newest point for -smooth ewma")
+	htmlReport := flag.Bool("html", false, "also write a self-contained HTML report of all graphs and metrics")
+	meta := logplot.Metadata{}
+	flag.Var(metaFlag{meta, logplot.MetadataClusterSize}, "cluster-size", "number of nodes of the cluster, shown in the HTML report")
+	flag.Var(metaFlag{meta, logplot.MetadataGitSHA}, "git-sha", "git SHA of the build under test, shown in the HTML report")
+	flag.Var(metaFlag{meta, ""}, "meta", "key=value describing the run, shown in the HTML report; may be repeated")
+	recordPath := flag.String("record", "", "append the metrics of the run to this trend store, shown by the trend subcommand")
+	build := flag.String("build", "", "build number of the run, recorded with -record")
+	flag.BoolVar(&opts.Lenient, "lenient", false, "skip and count malformed lines instead of failing")
+	followLog := flag.Bool("follow", false, "follow a growing log, like tail -F, and regenerate graphs as it grows")
+	refresh := flag.Duration("refresh", 30*time.Second, "how often to regenerate graphs when following a log")
+	httpAddr := flag.String("http", "", "when following a log, serve the graphs over HTTP on this address, e.g. localhost:8080")
+	promPath := flag.String("prom", "", "write the metrics of the runs in the Prometheus text format to this file, or - for stdout")
+	pushgateway := flag.String("pushgateway", "", "push the metrics of the runs to this Pushgateway, e.g. http://localhost:9091")
+	pushJob := flag.String("push-job", "kscale", "job of the metrics pushed to -pushgateway")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n       %s trend [flags]\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts.Output.Width = vg.Length(*width) * vg.Inch
+	opts.Output.Height = vg.Length(*height) * vg.Inch
+	if err := opts.Output.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := opts.Smoothing.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *promPath == "-" {
+		// keep stdout for the metrics
+		opts.Log = os.Stderr
+	}
+
+	lt, ok := logplot.Lookup(*dtype)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unsupported data type: %s. Supported types: %s.\n",
+			*dtype, strings.Join(logplot.Names(), ", "))
+		os.Exit(1)
+	}
+
+	if len(baseline) > 0 || len(candidate) > 0 {
+		if len(baseline) == 0 || len(candidate) == 0 {
+			fmt.Fprintln(os.Stderr, "Both -baseline and -candidate are needed to check for regressions.")
+			os.Exit(1)
+		}
+		checkRegression(lt, opts, baseline, candidate, regCfg)
+		return
+	}
+
+	if len(files) == 0 {
+		files.Set("data.txt")
+	}
+	if err := files.checkLabels(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(files) > 1 && *recordPath != "" {
+		fmt.Fprintln(os.Stderr, "Only a single run can be recorded.")
+		os.Exit(1)
+	}
+	if len(files) > 1 && lt.Compare == nil {
+		fmt.Fprintf(os.Stderr, "Data type %s does not support comparing runs.\n", *dtype)
+		os.Exit(1)
+	}
+
+	if *followLog {
+		if len(files) > 1 {
+			fmt.Fprintln(os.Stderr, "Only one log can be followed.")
+			os.Exit(1)
+		}
+		if !logplot.IsPlain(files[0].path) {
+			fmt.Fprintln(os.Stderr, "Only plain files can be followed.")
+			os.Exit(1)
+		}
+		if *httpAddr != "" {
+			serveOutput(*httpAddr, opts.Output, files[0].path, *refresh, opts.Log)
+		}
+		follow(lt, opts, files[0].path, *refresh, func(rs []logplot.Record) error {
+			return report(lt, opts, []logplot.Run{{Label: files[0].label, Records: rs}}, *htmlReport, meta)
+		})
+		return
+	}
+
+	var runs []logplot.Run
+	for _, rf := range files {
+		runs = append(runs, logplot.Run{Label: rf.label, Records: parseFile(lt, opts, rf.path)})
+	}
+
+	if err := report(lt, opts, runs, *htmlReport, meta); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	if *promPath != "" {
+		if err := writePrometheus(*promPath, *dtype, runs, meta, opts.Log); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			os.Exit(1)
+		}
+	}
+	if *pushgateway != "" {
+		grouping := map[string]string{"type": *dtype}
+		if err := logplot.PushPrometheus(*pushgateway, *pushJob, grouping, *dtype, runs, nil, meta); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(opts.Log, "successfully pushed metrics to %s\n", *pushgateway)
+	}
+
+	if *recordPath != "" {
+		if err := recordTrend(*recordPath, *dtype, *build, runs[0].Records, meta, opts.Log); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// writePrometheus writes the metrics of runs to the file at path, or to
+// stdout if path is -, and reports the file written to log.
+func writePrometheus(path, typ string, runs []logplot.Run, meta logplot.Metadata, log io.Writer) error {
+	if path == "-" {
+		return logplot.WritePrometheus(os.Stdout, typ, runs, nil, meta)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := logplot.WritePrometheus(f, typ, runs, nil, meta); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "successfully wrote Prometheus metrics to %s\n", path)
+	return nil
+}
+
+// report reports on a single run or compares several, and then writes
+// the HTML report if asked to.
+func report(lt logplot.LogType, opts logplot.Options, runs []logplot.Run, html bool, meta logplot.Metadata) error {
+	var graphs []string
+	opts.Graphs = &graphs
+
+	var err error
+	if len(runs) == 1 {
+		err = lt.Report(runs[0].Records, opts)
+	} else {
+		err = lt.Compare(runs, opts)
+	}
+	if err != nil || !html {
+		return err
+	}
+	return lt.WriteHTMLReport(runs, meta, graphs, opts)
+}
+
+// parseFile parses the log named by the input spec path, exiting on
+// failure. Skipped malformed lines are reported on stderr.
+func parseFile(lt logplot.LogType, opts logplot.Options, path string) []logplot.Record {
+	rs, bad, err := lt.ParseFile(path, opts)
+	if perr, ok := err.(*logplot.ParseError); ok {
+		fmt.Fprintf(os.Stderr, "Failed to parse %v\n", perr)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open file: %v\n", err)
+		os.Exit(1)
+	}
+	if len(bad) > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: skipped %d malformed lines, first at %v\n", len(bad), bad[0])
+	}
+	return rs
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/kscale/logplot"
+	"github.com/coreos/kscale/logplot/regression"
+)
+
+// checkRegression compares the metrics of the baseline runs against the
+// candidate runs, prints the comparison and exits with
+// regression.ExitRegressed if any metric regressed.
+func checkRegression(lt logplot.LogType, opts logplot.Options, baseline, candidate logplot.Globs, cfg regression.Config) {
+	rs, err := lt.DetectRegressions(parseRuns(lt, opts, baseline), parseRuns(lt, opts, candidate), cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	regressed := false
+	for _, r := range rs {
+		fmt.Println(r)
+		if r.Regressed {
+			regressed = true
+		}
+	}
+	if regressed {
+		os.Exit(regression.ExitRegressed)
+	}
+}
+
+// parseRuns parses every log matching patterns into a run labeled by its
+// input spec.
+func parseRuns(lt logplot.LogType, opts logplot.Options, patterns logplot.Globs) []logplot.Run {
+	specs, err := patterns.Specs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find runs: %v\n", err)
+		os.Exit(1)
+	}
+	var runs []logplot.Run
+	for _, spec := range specs {
+		runs = append(runs, logplot.Run{Label: spec, Records: parseFile(lt, opts, spec)})
+	}
+	return runs
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/kscale/logplot"
+)
+
+// runFlag is the value of a -f flag, of the form [label=]path.
+type runFlag struct {
+	label, path string
+}
+
+// runFlags collects repeated -f flags.
+type runFlags []runFlag
+
+func (fs *runFlags) String() string {
+	var ss []string
+	for _, f := range *fs {
+		ss = append(ss, f.label+"="+f.path)
+	}
+	return strings.Join(ss, ",")
+}
+
+func (fs *runFlags) Set(v string) error {
+	f := runFlag{path: v}
+	if i := strings.Index(v, "="); i != -1 {
+		f.label, f.path = v[:i], v[i+1:]
+	}
+	if f.path == "" {
+		return fmt.Errorf("empty path in %q", v)
+	}
+	if f.label == "" {
+		f.label = logplot.SpecName(f.path)
+	}
+	*fs = append(*fs, f)
+	return nil
+}
+
+// checkLabels returns an error if several runs have the same label, which
+// would mix them up in comparisons and metrics.
+func (fs runFlags) checkLabels() error {
+	paths := make(map[string]string)
+	for _, f := range fs {
+		if p, ok := paths[f.label]; ok {
+			return fmt.Errorf("Runs %s and %s are both labeled %s; label them apart with -f label=path.", p, f.path, f.label)
+		}
+		paths[f.label] = f.path
+	}
+	return nil
+}
+
+// metaFlag sets a key of run metadata. With an empty key, its values are
+// of the form key=value.
+type metaFlag struct {
+	meta logplot.Metadata
+	key  string
+}
+
+func (f metaFlag) String() string {
+	if f.meta == nil || f.key == "" {
+		return ""
+	}
+	return f.meta[f.key]
+}
+
+func (f metaFlag) Set(v string) error {
+	k := f.key
+	if k == "" {
+		i := strings.Index(v, "=")
+		if i < 1 {
+			return fmt.Errorf("want key=value, got %q", v)
+		}
+		k, v = v[:i], v[i+1:]
+	}
+	f.meta[k] = v
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/coreos/kscale/logplot"
+	"github.com/gonum/plot/vg"
+)
+
+// recordTrend appends the summary of a run to the trend store at path and
+// reports it to log.
+func recordTrend(path, typ, build string, rs []logplot.Record, meta logplot.Metadata, log io.Writer) error {
+	e, err := logplot.NewTrendEntry(typ, build, rs, meta)
+	if err != nil {
+		return err
+	}
+	if err := logplot.AppendTrend(path, e); err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "successfully recorded run to %s\n", path)
+	return nil
+}
+
+// trend implements the trend subcommand, which prints and plots metrics
+// over the last builds recorded in a trend store.
+func trend(args []string) {
+	opts := logplot.DefaultOptions()
+	opts.Log = os.Stdout
+
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	db := fs.String("db", "trend.jsonl", "trend store written by -record")
+	dtype := fs.String("t", "density", "data type: "+strings.Join(logplot.Names(), ", "))
+	n := fs.Int("n", 30, "number of most recent builds to show; 0 shows all")
+	var metrics stringsFlag
+	fs.Var(&metrics, "metric", "metric to plot; may be repeated (default all)")
+	fs.StringVar(&opts.Output.Dir, "o", opts.Output.Dir, "output directory")
+	fs.StringVar(&opts.Output.Prefix, "prefix", opts.Output.Prefix, "prefix of output file names")
+	fs.StringVar(&opts.Output.Format, "format", opts.Output.Format,
+		"image format of graphs: "+strings.Join(logplot.ImageFormats, ", "))
+	width := fs.Float64("width", 10, "width of graphs in inches")
+	height := fs.Float64("height", 10, "height of graphs in inches")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s trend [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts.Output.Width = vg.Length(*width) * vg.Inch
+	opts.Output.Height = vg.Length(*height) * vg.Inch
+	if err := opts.Output.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	f, err := os.Open(*db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open trend store: %v\n", err)
+		os.Exit(1)
+	}
+	all, err := logplot.ReadTrend(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", *db, err)
+		os.Exit(1)
+	}
+
+	es := logplot.LastBuilds(all, *dtype, *n)
+	if len(es) == 0 {
+		fmt.Fprintf(os.Stderr, "No %s runs recorded in %s.\n", *dtype, *db)
+		os.Exit(1)
+	}
+	if len(metrics) == 0 {
+		metrics = logplot.TrendMetrics(es)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "build\tdate\t%s\n", strings.Join(metrics, "\t"))
+	for _, e := range es {
+		fmt.Fprintf(w, "%s\t%s", e.Build, e.Time.Format("2006-01-02"))
+		for _, m := range metrics {
+			v, ok := e.Metrics[m]
+			if !ok {
+				fmt.Fprint(w, "\t-")
+				continue
+			}
+			fmt.Fprintf(w, "\t%s", strconv.FormatFloat(v, 'g', 6, 64))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	if err := logplot.PlotTrend(es, metrics, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+// stringsFlag collects the values of a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,316 @@
+package logplot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/kscale/logplot/regression"
+	"github.com/gonum/plot"
+	"github.com/gonum/plot/plotter"
+	"github.com/gonum/plot/plotutil"
+)
+
+// series reported by density logs
+const (
+	DensityCreated = "created"
+	DensityRunning = "running"
+	DensityPending = "pending"
+	DensityWaiting = "waiting"
+
+	DensityInactive           = "inactive"
+	DensityTerminating        = "terminating"
+	DensityUnknown            = "unknown"
+	DensityRunningButNotReady = "runningButNotReady"
+
+	// total number of pods to be scheduled and run
+	DensityTotal = "total"
+)
+
+// DensityPhases lists the pod phase series of density records in a
+// stable order.
+var DensityPhases = []string{
+	DensityCreated, DensityRunning, DensityPending, DensityWaiting,
+	DensityInactive, DensityTerminating, DensityUnknown, DensityRunningButNotReady,
+}
+
+func init() {
+	Register("density", LogType{
+		NewParser: func(o Options) LineParser { return NewDensityParser(o.FallbackInterval) },
+		Report:    ReportDensity,
+		Compare:   CompareDensity,
+		Metrics:   DensityMetrics,
+	})
+}
+
+func ReportDensity(rs []Record, o Options) error {
+	for _, report := range []func([]Record, Options) error{
+		plotDensity,
+		plotDensityRates,
+		recordAvgRunningRate,
+		recordDensitySummary,
+	} {
+		if err := report(rs, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func CompareDensity(runs []Run, o Options) error {
+	names := []string{DensityCreated, DensityRunning}
+	if o.AllPhases {
+		names = DensityPhases
+	}
+	if err := plotRuns(runs, o, "Density", "Seconds", "Number of Pods", "density-compare-all",
+		names, Points); err != nil {
+		return err
+	}
+	for _, r := range densityRates {
+		image := "density-compare-" + strings.TrimPrefix(r.image, "density-")
+		if err := plotRuns(runs, o, r.title, "Number of Pods", "Rate", image,
+			[]string{r.name}, o.Smoothing.points(RatePoints)); err != nil {
+			return err
+		}
+		if err := plotRuns(runs, o, r.title, "Seconds", "Rate", image+"-time",
+			[]string{r.name}, o.Smoothing.points(RateTimePoints)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DensityMetrics measures the throughput of a density run and how long it
+// took to get all pods running; if they never were, the duration of the
+// run is used instead. A run of fewer than two records cannot be measured
+// and has no metrics.
+func DensityMetrics(rs []Record) []Metric {
+	if len(rs) < 2 {
+		return nil
+	}
+	s := SummarizeDensity(rs)
+	toRunning := s.SecondsToAllRunning
+	if toRunning == nil {
+		toRunning = &rs[len(rs)-1].Seconds
+	}
+	return []Metric{
+		{Name: "avgCreatingRate", Value: s.CreatingRate.Avg, Direction: regression.HigherIsBetter},
+		{Name: "avgRunningRate", Value: s.RunningRate.Avg, Direction: regression.HigherIsBetter},
+		{Name: "secondsToAllRunning", Value: *toRunning, Direction: regression.LowerIsBetter},
+	}
+}
+
+// DensityParser parses density lines. The seconds of each record are
+// measured from the first density line. Lines without a timestamp prefix
+// are assumed to come a fallback interval after the previous one.
+type DensityParser struct {
+	clock logClock
+}
+
+func NewDensityParser(fallbackInterval time.Duration) *DensityParser {
+	return &DensityParser{clock: logClock{fallbackInterval: fallbackInterval}}
+}
+
+// wanted format:
+// Nov 25 23:05:18.250: INFO: densityN-X Pods: 12000 out of 12000 created, 1012 running,
+// 23 pending, 10965 waiting, 0 inactive, 0 terminating, 0 unknown, 0 runningButNotReady
+func (p *DensityParser) ParseLine(line string) (Record, bool, error) {
+	prefix, vs, ok, err := parsePodStatus(line)
+	if !ok || err != nil {
+		return Record{}, false, err
+	}
+	return Record{Seconds: p.clock.seconds(prefix), Values: vs}, true, nil
+}
+
+// parsePodStatus parses the pod status line the e2e framework logs while
+// it waits for the pods of a replication controller, e.g.
+// Nov 25 23:05:18.250: INFO: densityN-X Pods: 12000 out of 12000 created, 1012 running,
+// 23 pending, 10965 waiting, 0 inactive, 0 terminating, 0 unknown, 0 runningButNotReady
+// It returns what precedes the pod counts, and the counts keyed by their
+// density series. ok is false if line is not a pod status line.
+func parsePodStatus(line string) (prefix string, vs map[string]float64, ok bool, err error) {
+	statusFormat := "Pods: %d out of %d created, %d running, %d pending, %d waiting, " +
+		"%d inactive, %d terminating, %d unknown, %d runningButNotReady"
+
+	var created, total, running, pending, waiting int
+	var inactive, terminating, unknown, runningButNotReady int
+
+	if !strings.HasSuffix(line, "runningButNotReady") {
+		return "", nil, false, nil
+	}
+
+	pi := strings.Index(line, "Pods")
+	if pi == -1 {
+		return "", nil, false, errors.New("bad density format: cannot find Pods")
+	}
+
+	_, err = fmt.Sscanf(line[pi:], statusFormat, &created, &total, &running,
+		&pending, &waiting, &inactive, &terminating, &unknown, &runningButNotReady)
+
+	if err != nil {
+		return "", nil, false, fmt.Errorf("bad density format: %v", err)
+	}
+
+	return line[:pi], map[string]float64{
+		DensityCreated: float64(created),
+		DensityRunning: float64(running),
+		DensityPending: float64(pending),
+		DensityWaiting: float64(waiting),
+
+		DensityInactive:           float64(inactive),
+		DensityTerminating:        float64(terminating),
+		DensityUnknown:            float64(unknown),
+		DensityRunningButNotReady: float64(runningButNotReady),
+
+		DensityTotal: float64(total),
+	}, true, nil
+}
+
+// logClock measures the seconds of log lines from the first line it is
+// given. Lines without a timestamp prefix are assumed to come a fallback
+// interval after the previous one.
+type logClock struct {
+	fallbackInterval time.Duration
+
+	// timestamps of the first and the last line
+	start, last time.Time
+}
+
+// seconds returns the seconds of the line with the given prefix.
+func (c *logClock) seconds(prefix string) float64 {
+	t, ok := parseLogTime(prefix, c.last)
+	switch {
+	case !ok && c.last.IsZero():
+		t = time.Unix(0, 0)
+	case !ok:
+		t = c.last.Add(c.fallbackInterval)
+	}
+	if c.start.IsZero() {
+		c.start = t
+	}
+	c.last = t
+	return t.Sub(c.start).Seconds()
+}
+
+// parseLogTime parses the e2e framework timestamp at the start of
+// prefix, e.g. "Nov 25 23:05:18.250: INFO: densityN-X ". The log does not
+// record the year, so a timestamp over half a year earlier than prev is
+// taken to be in the following year. One slightly earlier comes from
+// goroutines logging concurrently, and is clamped to prev.
+func parseLogTime(prefix string, prev time.Time) (time.Time, bool) {
+	i := strings.Index(prefix, ": ")
+	if i == -1 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.StampMilli, strings.TrimSpace(prefix[:i]))
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !prev.IsZero() {
+		t = t.AddDate(prev.Year()-t.Year(), 0, 0)
+		switch {
+		case prev.Sub(t) > yearWrap:
+			t = t.AddDate(1, 0, 0)
+		case t.Before(prev):
+			t = prev
+		}
+	}
+	return t, true
+}
+
+// yearWrap is how much earlier than the previous line a timestamp must be
+// to have wrapped into the next year.
+const yearWrap = 183 * 24 * time.Hour
+
+func plotDensity(rs []Record, o Options) error {
+	names := DensityPhases[:4]
+	if o.AllPhases {
+		names = DensityPhases
+	}
+	return plotSeries(rs, o, "Density", "Number of Pods", "density-all", names...)
+}
+
+// densityRates lists the rate plots of density runs: the series, the plot
+// title and the name of the output image.
+var densityRates = []struct{ name, title, image string }{
+	{DensityCreated, "CreatingRate", "density-creating-rate"},
+	{DensityRunning, "RunningRate", "density-running-rate"},
+}
+
+// plotDensityRates plots the creating and running rates against the number
+// of pods and, suffixed by -time, against time.
+func plotDensityRates(rs []Record, o Options) error {
+	for _, r := range densityRates {
+		pts := o.Smoothing.Smooth(RatePoints(rs, r.name))
+		if err := plotRate(o, r.title, "Number of Pods", r.image, pts); err != nil {
+			return err
+		}
+		pts = o.Smoothing.Smooth(RateTimePoints(rs, r.name))
+		if err := plotRate(o, r.title, "Seconds", r.image+"-time", pts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func plotRate(o Options, title, xlabel, name string, pts plotter.XYs) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+
+	p.Title.Text = title
+	p.X.Label.Text = xlabel
+	p.Y.Label.Text = "Rate"
+
+	err = plotutil.AddLinePoints(p, title, pts)
+	if err != nil {
+		return err
+	}
+
+	return savePlot(p, o, name)
+}
+
+func recordAvgRunningRate(rs []Record, o Options) error {
+	f, err := o.Output.create("avg-running-rate.txt")
+	if err != nil {
+		return fmt.Errorf("cannot create '%s' file: %v", o.Output.Path("avg-running-rate.txt"), err)
+	}
+
+	defer f.Close()
+
+	r := AvgRunningRate(rs)
+	s := strconv.FormatFloat(r, 'f', 6, 64)
+	_, err = f.WriteString(s)
+	if err != nil {
+		return fmt.Errorf("failed to write to '%s': %v", o.Output.Path("avg-running-rate.txt"), err)
+	}
+	o.logf("successfully write average running rate to %s", o.Output.Path("avg-running-rate.txt"))
+	return nil
+}
+
+func CreatingRatePoints(rs []Record) plotter.XYs {
+	return RatePoints(rs, DensityCreated)
+}
+
+func RunningRatePoints(rs []Record) plotter.XYs {
+	return RatePoints(rs, DensityRunning)
+}
+
+// AvgRunningRate returns the average running rate until all pods are
+// running, or until the last record if they never are, counting the pods
+// running at the first record as started one interval before it.
+func AvgRunningRate(rs []Record) float64 {
+	return summarizeRate(rs, DensityRunning).Avg
+}
+
+// totalPods returns the total number of pods of a density run, as reported
+// by its last record.
+func totalPods(rs []Record) int {
+	if len(rs) == 0 {
+		return 0
+	}
+	return int(rs[len(rs)-1].Values[DensityTotal])
+}
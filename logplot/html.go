@@ -0,0 +1,180 @@
+package logplot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata describes the run a report is about, e.g. the size of the
+// cluster and the git SHA of the build under test.
+type Metadata map[string]string
+
+// metadata keys set by logplot's own flags
+const (
+	MetadataClusterSize = "cluster size"
+	MetadataGitSHA      = "git SHA"
+)
+
+// HTMLReportName is the name of the file written by WriteHTMLReport.
+const HTMLReportName = "report.html"
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+td.num { text-align: right; font-family: monospace; }
+figure { margin: 0 0 2em 0; }
+figure svg, figure img { max-width: 100%; height: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}}.</p>
+{{if .Metadata}}<h2>Run</h2>
+<table>
+{{range .Metadata}}<tr><th>{{.Key}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Metrics}}<h2>Summary</h2>
+<table>
+<tr><th>metric</th>{{range .Runs}}<th>{{.}}</th>{{end}}</tr>
+{{range .Metrics}}<tr><th>{{.Name}}</th>{{range .Values}}<td class="num">{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}<h2>Graphs</h2>
+{{range .Graphs}}<figure>
+{{.Content}}
+<figcaption>{{.Name}}</figcaption>
+</figure>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTMLReport writes a self-contained HTML report on runs into the
+// output directory. It shows meta, a table of the metrics of every run if
+// the log type measures runs, and the graphs at the given paths, as
+// collected in Options.Graphs by Report or Compare. SVG and PNG graphs are
+// embedded; other formats are linked to.
+func (lt LogType) WriteHTMLReport(runs []Run, meta Metadata, graphs []string, o Options) error {
+	data := struct {
+		Title     string
+		Generated string
+		Metadata  []struct{ Key, Value string }
+		Runs      []string
+		Metrics   []htmlMetric
+		Graphs    []htmlGraph
+	}{
+		Title:     "Scale test report",
+		Generated: time.Now().UTC().Format(time.RFC1123),
+	}
+
+	var keys []string
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		data.Metadata = append(data.Metadata, struct{ Key, Value string }{k, meta[k]})
+	}
+
+	for _, r := range runs {
+		data.Runs = append(data.Runs, r.Label)
+	}
+	if lt.Metrics != nil {
+		data.Metrics = htmlMetrics(lt, runs)
+	}
+
+	var err error
+	if data.Graphs, err = htmlGraphs(graphs); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	f, err := o.Output.create(HTMLReportName)
+	if err != nil {
+		return fmt.Errorf("cannot create '%s' file: %v", o.Output.Path(HTMLReportName), err)
+	}
+	defer f.Close()
+	if _, err := buf.WriteTo(f); err != nil {
+		return fmt.Errorf("failed to write to '%s': %v", o.Output.Path(HTMLReportName), err)
+	}
+	o.logf("successfully wrote HTML report to %s", o.Output.Path(HTMLReportName))
+	return nil
+}
+
+// htmlMetric is a row of the summary table: a metric and its value in
+// every run.
+type htmlMetric struct {
+	Name   string
+	Values []string
+}
+
+func htmlMetrics(lt LogType, runs []Run) []htmlMetric {
+	var rows []htmlMetric
+	index := make(map[string]int)
+	for i, r := range runs {
+		for _, m := range lt.Metrics(r.Records) {
+			j, ok := index[m.Key()]
+			if !ok {
+				j = len(rows)
+				index[m.Key()] = j
+				rows = append(rows, htmlMetric{Name: m.Key(), Values: make([]string, len(runs))})
+			}
+			rows[j].Values[i] = strconv.FormatFloat(m.Value, 'g', 6, 64)
+		}
+	}
+	return rows
+}
+
+// htmlGraph is a graph of the report and the HTML that shows it.
+type htmlGraph struct {
+	Name    string
+	Content template.HTML
+}
+
+func htmlGraphs(paths []string) ([]htmlGraph, error) {
+	var gs []htmlGraph
+	for _, p := range paths {
+		name := filepath.Base(p)
+		g := htmlGraph{Name: name}
+		switch strings.TrimPrefix(filepath.Ext(p), ".") {
+		case "svg":
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			// drop the XML declaration and doctype, which are not allowed inline
+			s := string(b)
+			if i := strings.Index(s, "<svg"); i != -1 {
+				s = s[i:]
+			}
+			g.Content = template.HTML(s)
+		case "png":
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			g.Content = template.HTML(fmt.Sprintf(`<img src="data:image/png;base64,%s" alt="%s">`,
+				base64.StdEncoding.EncodeToString(b), template.HTMLEscapeString(name)))
+		default:
+			g.Content = template.HTML(fmt.Sprintf(`<a href="%s">%s</a>`,
+				template.HTMLEscapeString(name), template.HTMLEscapeString(name)))
+		}
+		gs = append(gs, g)
+	}
+	return gs, nil
+}
@@ -0,0 +1,260 @@
+package logplot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Logs are named by input specs, which are one of
+//
+//	-                       standard input
+//	run.log                 a plain file
+//	run.log.gz              a gzipped file
+//	runs.tar.gz:run.log     a member of a tar archive, optionally gzipped
+//
+// An archive of a single file may be named without its member.
+
+// archiveSuffixes are the file name suffixes of tar archives.
+var archiveSuffixes = []string{".tar", ".tar.gz", ".tgz"}
+
+// splitSpec splits an input spec into the file and the archive member it
+// names, if any.
+func splitSpec(spec string) (file, member string) {
+	for _, suffix := range archiveSuffixes {
+		if i := strings.Index(spec, suffix+":"); i != -1 {
+			return spec[:i+len(suffix)], spec[i+len(suffix)+1:]
+		}
+	}
+	return spec, ""
+}
+
+func isArchive(file string) bool {
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(file, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isGzip(file string) bool {
+	return strings.HasSuffix(file, ".gz") || strings.HasSuffix(file, ".tgz")
+}
+
+// readCloser closes every closer in cs when closed.
+type readCloser struct {
+	io.Reader
+	cs []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var err error
+	for i := len(r.cs) - 1; i >= 0; i-- {
+		if cerr := r.cs[i].Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Open opens the log named by the input spec, decompressing it and
+// extracting it from its archive as needed.
+func Open(spec string) (io.ReadCloser, error) {
+	if spec == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+
+	file, member := splitSpec(spec)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	rc := &readCloser{Reader: f, cs: []io.Closer{f}}
+
+	if isGzip(file) {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		rc.Reader = zr
+		rc.cs = append(rc.cs, zr)
+	}
+	if !isArchive(file) {
+		return rc, nil
+	}
+
+	tr := tar.NewReader(rc.Reader)
+	var found []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if !isRegular(hdr) {
+			continue
+		}
+		if member != "" && hdr.Name == member {
+			rc.Reader = tr
+			return rc, nil
+		}
+		found = append(found, hdr.Name)
+	}
+	rc.Close()
+
+	if member == "" && len(found) == 1 {
+		// the archive had to be read to its end to tell it holds a
+		// single file, so open it again
+		return Open(file + ":" + found[0])
+	}
+	if member == "" {
+		return nil, fmt.Errorf("%s: archive holds %d files, name one of %v", file, len(found), found)
+	}
+	return nil, fmt.Errorf("%s: no member %s", file, member)
+}
+
+// Expand expands the glob patterns in an input spec, both in the file
+// and in the archive member, into the input specs of the matching logs.
+func Expand(pattern string) ([]string, error) {
+	if pattern == "-" {
+		return []string{pattern}, nil
+	}
+
+	filePattern, memberPattern := splitSpec(pattern)
+	files, err := filepath.Glob(filePattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %s", filePattern)
+	}
+	if memberPattern == "" {
+		return files, nil
+	}
+
+	var specs []string
+	for _, file := range files {
+		members, err := archiveMembers(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			ok, err := path.Match(memberPattern, m)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				specs = append(specs, file+":"+m)
+			}
+		}
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no archive members match %s", pattern)
+	}
+	return specs, nil
+}
+
+// Globs is a flag collecting repeated glob patterns of input specs.
+type Globs []string
+
+func (g *Globs) String() string { return fmt.Sprint(*g) }
+
+func (g *Globs) Set(v string) error {
+	if _, err := filepath.Match(v, ""); err != nil {
+		return err
+	}
+	*g = append(*g, v)
+	return nil
+}
+
+// Specs returns the input specs of all logs matching the patterns.
+func (g Globs) Specs() ([]string, error) {
+	var specs []string
+	for _, pattern := range g {
+		ms, err := Expand(pattern)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, ms...)
+	}
+	return specs, nil
+}
+
+// archiveMembers returns the sorted names of the regular files in the
+// tar archive file.
+func archiveMembers(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if isGzip(file) {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if isRegular(hdr) {
+			names = append(names, hdr.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isRegular(hdr *tar.Header) bool {
+	return hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA
+}
+
+// SpecName returns a short name for the log named by the input spec: its
+// base name without compression and file extension.
+func SpecName(spec string) string {
+	if spec == "-" {
+		return "stdin"
+	}
+	file, member := splitSpec(spec)
+	if member != "" {
+		file = member
+	}
+	name := filepath.Base(file)
+	for _, suffix := range []string{".tar.gz", ".tgz", ".tar", ".gz"} {
+		if strings.HasSuffix(name, suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// IsPlain reports whether the input spec names a plain file.
+func IsPlain(spec string) bool {
+	file, _ := splitSpec(spec)
+	return spec != "-" && !isArchive(file) && !isGzip(file)
+}
@@ -0,0 +1,393 @@
+package logplot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/kscale/logplot/regression"
+	"github.com/gonum/plot"
+	"github.com/gonum/plot/plotter"
+	"github.com/gonum/plot/plotutil"
+	"github.com/gonum/plot/vg"
+)
+
+// Latency records hold one series per latency percentile, in seconds,
+// named <latency>/<percentile>. A latency is either one reported by the
+// density test, e.g. "pod-startup" or "e2e-total", or an API call latency
+// named api/<verb> <resource>.
+const (
+	latencyPodStartup = "pod-startup"
+	latencyAPIPrefix  = "api/"
+)
+
+// LatencyPercentiles lists the percentiles reported for every latency.
+var LatencyPercentiles = []string{"perc50", "perc90", "perc99"}
+
+func init() {
+	Register("latency", LogType{
+		NewParser: func(Options) LineParser { return &LatencyParser{} },
+		Report:    ReportLatency,
+		Metrics:   LatencyMetrics,
+	})
+}
+
+// latencyMetric is the JSON form of latency percentiles in e2e logs.
+type latencyMetric struct {
+	Perc50 time.Duration `json:"Perc50"`
+	Perc90 time.Duration `json:"Perc90"`
+	Perc99 time.Duration `json:"Perc99"`
+}
+
+func (m latencyMetric) values(name string, vs map[string]float64) {
+	vs[name+"/perc50"] = m.Perc50.Seconds()
+	vs[name+"/perc90"] = m.Perc90.Seconds()
+	vs[name+"/perc99"] = m.Perc99.Seconds()
+}
+
+type podStartupLatency struct {
+	Latency latencyMetric `json:"latency"`
+}
+
+type apiResponsiveness struct {
+	APICalls []struct {
+		Resource string        `json:"resource"`
+		Verb     string        `json:"verb"`
+		Latency  latencyMetric `json:"latency"`
+	} `json:"apicalls"`
+}
+
+// LatencyParser parses the latency blocks of density logs. Every block
+// yields a record, whose seconds are measured from the first block.
+type LatencyParser struct {
+	// timestamps of the first and the last block
+	start, last time.Time
+
+	// name of the latency whose percentiles are on the next line
+	worst string
+
+	// JSON block being read and its time and kind
+	json      []string
+	jsonTime  time.Time
+	jsonKind  string
+	jsonDepth int
+}
+
+// wanted formats:
+// Jun 17 00:10:00.123: INFO: 10% worst e2e total latencies: [...]
+// Jun 17 00:10:00.123: INFO: perc50: 1.2s, perc90: 2.3s, perc99: 3.4s
+// Jun 17 00:10:00.123: INFO: Pod startup latency: {"latency": {"Perc50": 1200000000, ...}}
+// Jun 17 00:10:00.123: INFO: API calls latencies: {"apicalls": [{"resource": "pods", "verb": "LIST", "latency": {...}}]}
+// where the JSON may span several lines.
+func (p *LatencyParser) ParseLine(line string) (Record, bool, error) {
+	if p.json != nil {
+		return p.parseJSONLine(line)
+	}
+
+	if p.worst != "" && strings.Contains(line, "perc50: ") {
+		name := p.worst
+		p.worst = ""
+
+		var m latencyMetric
+		var perc50, perc90, perc99 string
+		pi := strings.Index(line, "perc50: ")
+		_, err := fmt.Sscanf(strings.Replace(line[pi:], ",", " ", -1), "perc50: %s perc90: %s perc99: %s",
+			&perc50, &perc90, &perc99)
+		if err != nil {
+			return Record{}, false, fmt.Errorf("bad latency percentiles: %v", err)
+		}
+		for _, d := range []struct {
+			s string
+			d *time.Duration
+		}{{perc50, &m.Perc50}, {perc90, &m.Perc90}, {perc99, &m.Perc99}} {
+			if *d.d, err = time.ParseDuration(d.s); err != nil {
+				return Record{}, false, fmt.Errorf("bad latency percentiles: %v", err)
+			}
+		}
+
+		vs := make(map[string]float64)
+		m.values(name, vs)
+		return p.record(p.time(line[:pi]), vs), true, nil
+	}
+
+	if i := strings.Index(line, "% worst "); i != -1 {
+		rest := line[i+len("% worst "):]
+		if j := strings.Index(rest, " latencies"); j != -1 {
+			p.worst = strings.Replace(rest[:j], " ", "-", -1)
+		}
+		return Record{}, false, nil
+	}
+
+	for _, kind := range []string{"Pod startup latency: ", "API calls latencies: "} {
+		i := strings.Index(line, kind)
+		if i == -1 {
+			continue
+		}
+		p.jsonTime = p.time(line[:i])
+		p.jsonKind = kind
+		p.json = []string{}
+		return p.parseJSONLine(line[i+len(kind):])
+	}
+	return Record{}, false, nil
+}
+
+// parseJSONLine adds line to the JSON block being read, and parses the
+// block once its braces balance.
+func (p *LatencyParser) parseJSONLine(line string) (Record, bool, error) {
+	p.json = append(p.json, line)
+	p.jsonDepth += strings.Count(line, "{") - strings.Count(line, "}")
+	if p.jsonDepth > 0 {
+		return Record{}, false, nil
+	}
+
+	data := []byte(strings.Join(p.json, "\n"))
+	kind := p.jsonKind
+	p.json, p.jsonKind, p.jsonDepth = nil, "", 0
+
+	vs := make(map[string]float64)
+	switch kind {
+	case "Pod startup latency: ":
+		var l podStartupLatency
+		if err := json.Unmarshal(data, &l); err != nil {
+			return Record{}, false, fmt.Errorf("bad pod startup latency: %v", err)
+		}
+		l.Latency.values(latencyPodStartup, vs)
+	case "API calls latencies: ":
+		var a apiResponsiveness
+		if err := json.Unmarshal(data, &a); err != nil {
+			return Record{}, false, fmt.Errorf("bad API calls latencies: %v", err)
+		}
+		for _, c := range a.APICalls {
+			c.Latency.values(latencyAPIPrefix+c.Verb+" "+c.Resource, vs)
+		}
+	}
+	return p.record(p.jsonTime, vs), true, nil
+}
+
+// End reports a JSON block the log ends in the middle of.
+func (p *LatencyParser) End() error {
+	if p.json == nil {
+		return nil
+	}
+	return fmt.Errorf("log ends in the middle of the %s block of %d lines",
+		strings.TrimSuffix(p.jsonKind, ": "), len(p.json))
+}
+
+// time returns the time of a line from its prefix.
+func (p *LatencyParser) time(prefix string) time.Time {
+	t, ok := parseLogTime(prefix, p.last)
+	if !ok {
+		// lines without timestamps are kept in order one second apart
+		t = p.last.Add(time.Second)
+	}
+	if p.start.IsZero() {
+		p.start = t
+	}
+	p.last = t
+	return t
+}
+
+func (p *LatencyParser) record(t time.Time, vs map[string]float64) Record {
+	return Record{Seconds: t.Sub(p.start).Seconds(), Values: vs}
+}
+
+// Latency is the percentiles of a latency, in seconds.
+type Latency struct {
+	Perc50 float64 `json:"perc50"`
+	Perc90 float64 `json:"perc90"`
+	Perc99 float64 `json:"perc99"`
+}
+
+// LatencySummary is the machine-readable summary of the latencies of a
+// density run. It holds the last reported percentiles of every latency.
+type LatencySummary struct {
+	Latencies map[string]Latency `json:"latencies"`
+	APICalls  []APICallLatency   `json:"apiCalls"`
+
+	Series []Sample `json:"series"`
+}
+
+// APICallLatency is the latency of API calls of a verb on a resource.
+type APICallLatency struct {
+	Verb     string  `json:"verb"`
+	Resource string  `json:"resource"`
+	Latency  Latency `json:"latency"`
+}
+
+func SummarizeLatency(rs []Record) LatencySummary {
+	s := LatencySummary{Latencies: make(map[string]Latency)}
+	last := make(map[string]float64)
+	for _, r := range rs {
+		// API call latencies are reported together, so only keep the last block
+		if hasAPICalls(r) {
+			for k := range last {
+				if strings.HasPrefix(k, latencyAPIPrefix) {
+					delete(last, k)
+				}
+			}
+		}
+		for k, v := range r.Values {
+			last[k] = v
+		}
+		s.Series = append(s.Series, Sample{Seconds: r.Seconds, Values: r.Values})
+	}
+
+	for _, name := range latencyNames(last) {
+		l := Latency{
+			Perc50: last[name+"/perc50"],
+			Perc90: last[name+"/perc90"],
+			Perc99: last[name+"/perc99"],
+		}
+		if !strings.HasPrefix(name, latencyAPIPrefix) {
+			s.Latencies[name] = l
+			continue
+		}
+		verb, resource := splitAPICall(name)
+		s.APICalls = append(s.APICalls, APICallLatency{Verb: verb, Resource: resource, Latency: l})
+	}
+	return s
+}
+
+func hasAPICalls(r Record) bool {
+	for k := range r.Values {
+		if strings.HasPrefix(k, latencyAPIPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// latencyNames returns the sorted names of the latencies in vs.
+func latencyNames(vs map[string]float64) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for k := range vs {
+		name := k[:strings.LastIndex(k, "/")]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func splitAPICall(name string) (verb, resource string) {
+	name = strings.TrimPrefix(name, latencyAPIPrefix)
+	if i := strings.Index(name, " "); i != -1 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// latencyQuantiles maps LatencyPercentiles to the quantiles they are.
+var latencyQuantiles = map[string]string{"perc50": "0.5", "perc90": "0.9", "perc99": "0.99"}
+
+// quantiles returns the percentiles of l by quantile.
+func (l Latency) quantiles() map[string]float64 {
+	return map[string]float64{"0.5": l.Perc50, "0.9": l.Perc90, "0.99": l.Perc99}
+}
+
+// LatencyMetrics measures the last reported percentiles of every latency
+// as "seconds" labeled by latency and quantile, and of every API call as
+// "apiCallSeconds" labeled by verb, resource and quantile.
+func LatencyMetrics(rs []Record) []Metric {
+	s := SummarizeLatency(rs)
+
+	var names []string
+	for name := range s.Latencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ms []Metric
+	for _, name := range names {
+		qs := s.Latencies[name].quantiles()
+		for _, perc := range LatencyPercentiles {
+			q := latencyQuantiles[perc]
+			ms = append(ms, Metric{
+				Name:      "seconds",
+				Labels:    map[string]string{"latency": name, "quantile": q},
+				Value:     qs[q],
+				Direction: regression.LowerIsBetter,
+			})
+		}
+	}
+	for _, c := range s.APICalls {
+		qs := c.Latency.quantiles()
+		for _, perc := range LatencyPercentiles {
+			q := latencyQuantiles[perc]
+			ms = append(ms, Metric{
+				Name:      "apiCallSeconds",
+				Labels:    map[string]string{"verb": c.Verb, "resource": c.Resource, "quantile": q},
+				Value:     qs[q],
+				Direction: regression.LowerIsBetter,
+			})
+		}
+	}
+	return ms
+}
+
+func ReportLatency(rs []Record, o Options) error {
+	s := SummarizeLatency(rs)
+
+	var names []string
+	for name := range s.Latencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var series []string
+		for _, perc := range LatencyPercentiles {
+			series = append(series, name+"/"+perc)
+		}
+		if err := plotSeries(rs, o, "Latency: "+name, "Seconds", "latency-"+name, series...); err != nil {
+			return err
+		}
+	}
+
+	if len(s.APICalls) > 0 {
+		if err := plotAPICallLatency(s.APICalls, o); err != nil {
+			return err
+		}
+	}
+	return writeJSON(s, o, "latency-summary.json")
+}
+
+// plotAPICallLatency plots the latency percentiles of every API call as
+// grouped bars.
+func plotAPICallLatency(calls []APICallLatency, o Options) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+
+	p.Title.Text = "API Call Latency"
+	p.Y.Label.Text = "Seconds"
+
+	var labels []string
+	for _, c := range calls {
+		labels = append(labels, c.Verb+" "+c.Resource)
+	}
+	p.NominalX(labels...)
+
+	width := vg.Points(8)
+	for i, perc := range LatencyPercentiles {
+		vs := make(plotter.Values, len(calls))
+		for j, c := range calls {
+			vs[j] = c.Latency.quantiles()[latencyQuantiles[perc]]
+		}
+		bars, err := plotter.NewBarChart(vs, width)
+		if err != nil {
+			return err
+		}
+		bars.Color = plotutil.Color(i)
+		bars.Offset = vg.Length(i-1) * width
+		p.Add(bars)
+		p.Legend.Add(perc, bars)
+	}
+
+	return savePlot(p, o, "latency-api")
+}
@@ -0,0 +1,92 @@
+package logplot
+
+import (
+	"strings"
+	"time"
+)
+
+// Load records hold the density series summed over every replication
+// controller of the load test, plus the number of replication controllers
+// reported so far.
+const LoadRCs = "rcs"
+
+func init() {
+	Register("load", LogType{
+		NewParser: func(o Options) LineParser { return NewLoadParser(o.FallbackInterval) },
+		Report:    ReportLoad,
+		Compare:   CompareLoad,
+	})
+}
+
+func ReportLoad(rs []Record, o Options) error {
+	names := DensityPhases[:4]
+	if o.AllPhases {
+		names = DensityPhases
+	}
+	if err := plotSeries(rs, o, "Load", "Number of Pods", "load-all", names...); err != nil {
+		return err
+	}
+	if err := plotSeries(rs, o, "Load", "Replication Controllers", "load-rcs", LoadRCs); err != nil {
+		return err
+	}
+	pts := o.Smoothing.Smooth(RateTimePoints(rs, DensityRunning))
+	return plotRate(o, "RunningRate", "Seconds", "load-running-rate-time", pts)
+}
+
+func CompareLoad(runs []Run, o Options) error {
+	names := []string{DensityCreated, DensityRunning}
+	if o.AllPhases {
+		names = DensityPhases
+	}
+	if err := plotRuns(runs, o, "Load", "Seconds", "Number of Pods", "load-compare-all",
+		names, Points); err != nil {
+		return err
+	}
+	return plotRuns(runs, o, "RunningRate", "Seconds", "Rate", "load-compare-running-rate-time",
+		[]string{DensityRunning}, o.Smoothing.points(RateTimePoints))
+}
+
+// LoadParser parses the pod status lines of the kubemark load test. Unlike
+// the density test, the load test creates many replication controllers at
+// once, whose status lines interleave. Every status line yields a record
+// of the latest status of every replication controller reported so far,
+// summed. The seconds of each record are measured from the first status
+// line, as for density logs.
+type LoadParser struct {
+	clock logClock
+
+	// latest status of every replication controller, by name
+	rcs map[string]map[string]float64
+}
+
+func NewLoadParser(fallbackInterval time.Duration) *LoadParser {
+	return &LoadParser{
+		clock: logClock{fallbackInterval: fallbackInterval},
+		rcs:   make(map[string]map[string]float64),
+	}
+}
+
+// wanted format:
+// Jun 17 00:04:46.123: INFO: load-small-12 Pods: 5 out of 5 created, 3 running,
+// 2 pending, 0 waiting, 0 inactive, 0 terminating, 0 unknown, 0 runningButNotReady
+func (p *LoadParser) ParseLine(line string) (Record, bool, error) {
+	prefix, vs, ok, err := parsePodStatus(line)
+	if !ok || err != nil {
+		return Record{}, false, err
+	}
+
+	// the replication controller is named right before the pod counts
+	var rc string
+	if fs := strings.Fields(prefix); len(fs) > 0 {
+		rc = fs[len(fs)-1]
+	}
+	p.rcs[rc] = vs
+
+	sum := map[string]float64{LoadRCs: float64(len(p.rcs))}
+	for _, rvs := range p.rcs {
+		for name, v := range rvs {
+			sum[name] += v
+		}
+	}
+	return Record{Seconds: p.clock.seconds(prefix), Values: sum}, true, nil
+}
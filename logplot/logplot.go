@@ -0,0 +1,233 @@
+// Package logplot parses the logs of Kubernetes scale tests into time
+// series, and plots and summarizes them.
+package logplot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/kscale/logplot/regression"
+)
+
+// Record is a single sample of one or more time series parsed from a log.
+type Record struct {
+	// x axis
+	Seconds float64
+
+	// y axis, keyed by series name
+	Values map[string]float64
+}
+
+// LineParser parses the records of a log one line at a time.
+type LineParser interface {
+	// ParseLine returns the record found in line, if any. It returns an
+	// error if line looks like a record but is malformed.
+	ParseLine(line string) (Record, bool, error)
+}
+
+// Ender is implemented by line parsers whose records may span several
+// lines. End is called at the end of a log, and returns an error if the
+// log ended in the middle of a record.
+type Ender interface {
+	End() error
+}
+
+// ParseError is a malformed line of a log, or a failure to read it.
+type ParseError struct {
+	// input spec of the log, if known
+	File string
+	// line number, starting at 1
+	Line int
+	// text of the line; empty if it could not be read
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	if e.Text != "" {
+		msg += fmt.Sprintf(": %q", e.Text)
+	}
+	if e.File != "" {
+		msg = e.File + ": " + msg
+	}
+	return msg
+}
+
+// Options configures how logs are parsed and reported.
+type Options struct {
+	// interval between density lines, assumed for lines without a timestamp
+	FallbackInterval time.Duration
+	// whether to plot inactive, terminating, unknown and runningButNotReady pods
+	AllPhases bool
+	// whether to skip malformed lines instead of failing
+	Lenient bool
+	// smoothing of rate plots
+	Smoothing Smoothing
+
+	Output Output
+
+	// Log receives a line for every file written; nil discards them.
+	Log io.Writer
+
+	// Graphs, if set, collects the paths of the graphs written, e.g. to
+	// embed in an HTML report.
+	Graphs *[]string
+}
+
+// DefaultOptions returns the options logplot uses unless told otherwise.
+func DefaultOptions() Options {
+	return Options{
+		FallbackInterval: 10 * time.Second,
+		Output:           DefaultOutput(),
+	}
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Log != nil {
+		fmt.Fprintf(o.Log, format+"\n", args...)
+	}
+}
+
+// LogType knows how to parse one kind of log into records and
+// how to report on the parsed records.
+type LogType struct {
+	// NewParser returns a parser for a new log.
+	NewParser func(o Options) LineParser
+	Report    func(rs []Record, o Options) error
+	// Compare reports on several runs side by side; nil if the log
+	// type does not support comparison.
+	Compare func(runs []Run, o Options) error
+	// Metrics measures a run for regression detection; nil if the log
+	// type does not support it. It returns no metrics for a run it cannot
+	// measure, e.g. one too short to have a rate.
+	Metrics func(rs []Record) []Metric
+}
+
+// Parse reads all records from r. A malformed line, or a record the log
+// ends in the middle of, fails the parse with a *ParseError, unless
+// o.Lenient is set, in which case the line is skipped and its error
+// returned in bad. A failure to read r, e.g. a truncated gzipped log,
+// fails the parse with a *ParseError of the line being read.
+func (lt LogType) Parse(r io.Reader, o Options) (results []Record, bad []*ParseError, err error) {
+	p := lt.NewParser(o)
+	br := bufio.NewReader(r)
+	last := 0
+	for n := 1; ; n++ {
+		bytes, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return results, bad, &ParseError{Line: n, Err: err}
+		}
+		if len(bytes) == 0 && err == io.EOF {
+			break
+		}
+		last = n
+
+		line := strings.TrimSpace(string(bytes))
+		rec, ok, perr := p.ParseLine(line)
+		switch {
+		case perr != nil && o.Lenient:
+			bad = append(bad, &ParseError{Line: n, Text: line, Err: perr})
+		case perr != nil:
+			return results, bad, &ParseError{Line: n, Text: line, Err: perr}
+		case ok:
+			results = append(results, rec)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if e, ok := p.(Ender); ok {
+		if perr := e.End(); perr != nil {
+			if !o.Lenient {
+				return results, bad, &ParseError{Line: last, Err: perr}
+			}
+			bad = append(bad, &ParseError{Line: last, Err: perr})
+		}
+	}
+	return results, bad, nil
+}
+
+// ParseFile opens the log named by the input spec and parses it like
+// Parse. Parse errors name the spec.
+func (lt LogType) ParseFile(spec string, o Options) (results []Record, bad []*ParseError, err error) {
+	f, err := Open(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	results, bad, err = lt.Parse(f, o)
+	for _, b := range bad {
+		b.File = spec
+	}
+	if perr, ok := err.(*ParseError); ok {
+		perr.File = spec
+	}
+	return results, bad, err
+}
+
+// Run is the parsed log of one test run, labeled to tell it apart from
+// other runs in comparison graphs.
+type Run struct {
+	Label   string
+	Records []Record
+}
+
+// Metric is a scalar measure of a run.
+type Metric struct {
+	Name string
+	// tell apart metrics of the same name, e.g. the quantiles of a latency
+	Labels    map[string]string
+	Value     float64
+	Direction regression.Direction
+}
+
+// Key returns the name and the sorted labels of m, e.g.
+// seconds{latency="pod-startup",quantile="0.99"}, which tell it apart
+// from the other metrics of a run.
+func (m Metric) Key() string {
+	if len(m.Labels) == 0 {
+		return m.Name
+	}
+	var ls []string
+	for k, v := range m.Labels {
+		ls = append(ls, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(ls)
+	return m.Name + "{" + strings.Join(ls, ",") + "}"
+}
+
+// logTypes maps log type names to the log type handling them.
+var logTypes = make(map[string]LogType)
+
+// Register makes a log type available by name. It panics if the name is
+// already registered.
+func Register(name string, lt LogType) {
+	if _, ok := logTypes[name]; ok {
+		panic("logplot: log type registered twice: " + name)
+	}
+	logTypes[name] = lt
+}
+
+// Lookup returns the log type registered by name.
+func Lookup(name string) (LogType, bool) {
+	lt, ok := logTypes[name]
+	return lt, ok
+}
+
+// Names returns the sorted names of all registered log types.
+func Names() []string {
+	names := make([]string, 0, len(logTypes))
+	for name := range logTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,699 @@
+package logplot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/kscale/logplot/regression"
+)
+
+func densityLine(prefix string, created, total, running int) string {
+	return fmt.Sprintf("%sINFO: density30-0 Pods: %d out of %d created, %d running, 0 pending, 0 waiting, "+
+		"0 inactive, 0 terminating, 0 unknown, 0 runningButNotReady", prefix, created, total, running)
+}
+
+func mustLookup(t *testing.T, name string) LogType {
+	lt, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("%s log type is not registered", name)
+	}
+	return lt
+}
+
+func TestParseDensity(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     string
+		lenient bool
+
+		seconds []float64
+		running []float64
+		bad     []int
+		errLine int
+	}{
+		{
+			name: "timestamps",
+			log: densityLine("Nov 25 23:05:18.250: ", 10, 30, 0) + "\n" +
+				"Nov 25 23:05:20.000: INFO: unrelated line\n" +
+				densityLine("Nov 25 23:05:30.250: ", 30, 30, 20) + "\n",
+			seconds: []float64{0, 12},
+			running: []float64{0, 20},
+		},
+		{
+			name: "no timestamps",
+			log: densityLine("", 10, 30, 0) + "\n" +
+				densityLine("", 30, 30, 20) + "\n" +
+				densityLine("", 30, 30, 30) + "\n",
+			seconds: []float64{0, 10, 20},
+			running: []float64{0, 20, 30},
+		},
+		{
+			name: "new year",
+			log: densityLine("Dec 31 23:59:55.000: ", 10, 30, 0) + "\n" +
+				densityLine("Jan  1 00:00:05.000: ", 30, 30, 20) + "\n",
+			seconds: []float64{0, 10},
+			running: []float64{0, 20},
+		},
+		{
+			name: "out of order",
+			log: densityLine("Jun 17 00:04:46.500: ", 10, 30, 0) + "\n" +
+				densityLine("Jun 17 00:04:46.400: ", 20, 30, 10) + "\n" +
+				densityLine("Jun 17 00:04:56.500: ", 30, 30, 30) + "\n",
+			seconds: []float64{0, 0, 10},
+			running: []float64{0, 10, 30},
+		},
+		{
+			name: "unterminated last line",
+			log: densityLine("Nov 25 23:05:18.250: ", 10, 30, 0) + "\n" +
+				densityLine("Nov 25 23:05:28.250: ", 30, 30, 20),
+			seconds: []float64{0, 10},
+			running: []float64{0, 20},
+		},
+		{
+			name: "malformed line",
+			log: densityLine("", 10, 30, 0) + "\n" +
+				"INFO: density30-0 Pods: 1x out of 30 created, 0 runningButNotReady\n" +
+				densityLine("", 30, 30, 20) + "\n",
+			seconds: []float64{0},
+			running: []float64{0},
+			errLine: 2,
+		},
+		{
+			name: "malformed line lenient",
+			log: densityLine("", 10, 30, 0) + "\n" +
+				"INFO: density30-0 Pods: 1x out of 30 created, 0 runningButNotReady\n" +
+				densityLine("", 30, 30, 20) + "\n",
+			lenient: true,
+			seconds: []float64{0, 10},
+			running: []float64{0, 20},
+			bad:     []int{2},
+		},
+	}
+
+	lt := mustLookup(t, "density")
+	for _, tt := range tests {
+		o := DefaultOptions()
+		o.Lenient = tt.lenient
+		rs, bad, err := lt.Parse(strings.NewReader(tt.log), o)
+
+		var errLine int
+		if perr, ok := err.(*ParseError); ok {
+			errLine = perr.Line
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if errLine != tt.errLine {
+			t.Errorf("%s: error at line %d, want %d", tt.name, errLine, tt.errLine)
+		}
+
+		var badLines []int
+		for _, b := range bad {
+			badLines = append(badLines, b.Line)
+		}
+		if !reflect.DeepEqual(badLines, tt.bad) {
+			t.Errorf("%s: bad lines %v, want %v", tt.name, badLines, tt.bad)
+		}
+
+		var seconds, running []float64
+		for _, r := range rs {
+			seconds = append(seconds, r.Seconds)
+			running = append(running, r.Values[DensityRunning])
+		}
+		if !reflect.DeepEqual(seconds, tt.seconds) {
+			t.Errorf("%s: seconds %v, want %v", tt.name, seconds, tt.seconds)
+		}
+		if !reflect.DeepEqual(running, tt.running) {
+			t.Errorf("%s: running %v, want %v", tt.name, running, tt.running)
+		}
+	}
+}
+
+func TestSummarizeDensity(t *testing.T) {
+	log := densityLine("", 10, 30, 0) + "\n" +
+		densityLine("", 30, 30, 20) + "\n" +
+		densityLine("", 30, 30, 25) + "\n" +
+		densityLine("", 30, 30, 30) + "\n"
+	o := DefaultOptions()
+	o.FallbackInterval = 5 * time.Second
+	rs, _, err := mustLookup(t, "density").Parse(strings.NewReader(log), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := SummarizeDensity(rs)
+	if s.TotalPods != 30 {
+		t.Errorf("total pods %d, want 30", s.TotalPods)
+	}
+	if s.SecondsToAllCreated == nil || *s.SecondsToAllCreated != 5 {
+		t.Errorf("seconds to all created %v, want 5", s.SecondsToAllCreated)
+	}
+	if s.SecondsToAllRunning == nil || *s.SecondsToAllRunning != 15 {
+		t.Errorf("seconds to all running %v, want 15", s.SecondsToAllRunning)
+	}
+	wr := RateSummary{Avg: 1.5, Peak: 4, P50: 1, P99: 4}
+	if s.RunningRate != wr {
+		t.Errorf("running rate %+v, want %+v", s.RunningRate, wr)
+	}
+	if r := AvgRunningRate(rs); r != 1.5 {
+		t.Errorf("average running rate %v, want 1.5", r)
+	}
+}
+
+func TestSummarizeRateSameTime(t *testing.T) {
+	log := densityLine("Jun 17 00:00:00.000: ", 30, 30, 0) + "\n" +
+		densityLine("Jun 17 00:00:10.000: ", 30, 30, 10) + "\n" +
+		densityLine("Jun 17 00:00:10.000: ", 30, 30, 10) + "\n" +
+		// clamped to the line before
+		densityLine("Jun 17 00:00:09.900: ", 30, 30, 10) + "\n" +
+		densityLine("Jun 17 00:00:20.000: ", 30, 30, 30) + "\n"
+	rs, _, err := mustLookup(t, "density").Parse(strings.NewReader(log), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RateSummary{Avg: 1, Peak: 2, P50: 1, P99: 2}
+	if s := summarizeRate(rs, DensityRunning); s != want {
+		t.Errorf("running rate %+v, want %+v", s, want)
+	}
+}
+
+// TestAvgRunningRate pins the average running rate, which counts the pods
+// running at the first density line as started one interval before it.
+func TestAvgRunningRate(t *testing.T) {
+	tests := []struct {
+		log  []string
+		want float64
+	}{
+		// lines without timestamps are 10s apart
+		{[]string{"0", "20", "30"}, 1},
+		{[]string{"10", "20", "30"}, 1},
+		{[]string{"0", "15", "25"}, 25.0 / 30},
+		{[]string{"30"}, 0},
+		{[]string{"30", "30"}, 3},
+		// the interval is measured between the first two lines
+		{[]string{"00:00:00.000 0", "00:00:05.000 10", "00:00:20.000 30"}, 30.0 / 25},
+	}
+	for _, tt := range tests {
+		var log string
+		for _, l := range tt.log {
+			prefix := ""
+			if i := strings.Index(l, " "); i != -1 {
+				prefix, l = "Jun 17 "+l[:i]+": ", l[i+1:]
+			}
+			running, _ := strconv.Atoi(l)
+			log += densityLine(prefix, 30, 30, running) + "\n"
+		}
+		rs, _, err := mustLookup(t, "density").Parse(strings.NewReader(log), DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := AvgRunningRate(rs); r != tt.want {
+			t.Errorf("%q: average running rate %v, want %v", tt.log, r, tt.want)
+		}
+	}
+}
+
+func TestParseLoad(t *testing.T) {
+	line := func(prefix, rc string, created, total, running int) string {
+		return strings.Replace(densityLine(prefix, created, total, running), "density30-0", rc, 1)
+	}
+	// the status of concurrent replication controllers may be logged out
+	// of order
+	log := line("Jun 17 00:04:46.000: ", "load-small-1", 3, 5, 0) + "\n" +
+		line("Jun 17 00:04:45.900: ", "load-medium-1", 10, 30, 2) + "\n" +
+		"Jun 17 00:04:49.000: INFO: Created replication controller with name: load-small-2\n" +
+		line("Jun 17 00:04:50.000: ", "load-small-1", 5, 5, 5) + "\n" +
+		line("Jun 17 00:04:56.000: ", "load-medium-1", 30, 30, 30)
+
+	rs, _, err := mustLookup(t, "load").Parse(strings.NewReader(log), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seconds, running, total, rcs []float64
+	for _, r := range rs {
+		seconds = append(seconds, r.Seconds)
+		running = append(running, r.Values[DensityRunning])
+		total = append(total, r.Values[DensityTotal])
+		rcs = append(rcs, r.Values[LoadRCs])
+	}
+	for _, c := range []struct {
+		name      string
+		got, want []float64
+	}{
+		{"seconds", seconds, []float64{0, 0, 4, 10}},
+		{"running", running, []float64{0, 2, 7, 35}},
+		{"total", total, []float64{5, 35, 35, 35}},
+		{"rcs", rcs, []float64{1, 2, 2, 2}},
+	} {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// TestParseSchedBench parses the sample scheduler benchmark output
+// straight from its archive.
+func TestParseSchedBench(t *testing.T) {
+	specs, err := Expand("schedulerbench/bench-data.tar.gz:bench-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wspecs := []string{
+		"schedulerbench/bench-data.tar.gz:bench-new.txt",
+		"schedulerbench/bench-data.tar.gz:bench-old.txt",
+	}
+	if !reflect.DeepEqual(specs, wspecs) {
+		t.Fatalf("expanded to %v, want %v", specs, wspecs)
+	}
+
+	lt := mustLookup(t, "scheduler-bench")
+	for _, spec := range specs {
+		f, err := Open(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, _, err := lt.Parse(f, DefaultOptions())
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", spec, err)
+			continue
+		}
+
+		if len(rs) != 585 {
+			t.Errorf("%s: %d records, want 585", spec, len(rs))
+		}
+		ms := lt.Metrics(rs)
+		if len(ms) != 2 {
+			t.Fatalf("%s: %d metrics, want 2", spec, len(ms))
+		}
+		if w := 30000.0 / 588; math.Abs(ms[0].Value-w) > 1e-9 {
+			t.Errorf("%s: throughput %v, want %v", spec, ms[0].Value, w)
+		}
+		if ms[1].Value != 588 {
+			t.Errorf("%s: seconds to complete %v, want 588", spec, ms[1].Value)
+		}
+	}
+}
+
+// TestParseFileErrors checks that read errors, like those of a truncated
+// gzipped log, and malformed lines fail with the file and the line.
+func TestParseFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var log bytes.Buffer
+	zw := gzip.NewWriter(&log)
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(zw, "%ds\trate: %d\ttotal: %d\n", i, i%97, i*i%10007)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "truncated.txt.gz")
+	if err := ioutil.WriteFile(truncated, log.Bytes()[:log.Len()/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	malformed := filepath.Join(dir, "malformed.txt")
+	if err := ioutil.WriteFile(malformed, []byte("1s\trate: 1\ttotal: 1\n2s\trate: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lt := mustLookup(t, "scheduler-bench")
+	for _, tt := range []struct {
+		path string
+		line int
+	}{
+		{truncated, 0},
+		{malformed, 2},
+	} {
+		_, _, err := lt.ParseFile(tt.path, DefaultOptions())
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%s: error %v, want a *ParseError", tt.path, err)
+			continue
+		}
+		if perr.File != tt.path {
+			t.Errorf("%s: error of file %q", tt.path, perr.File)
+		}
+		if perr.Line < 1 || tt.line != 0 && perr.Line != tt.line {
+			t.Errorf("%s: error at line %d, want %d", tt.path, perr.Line, tt.line)
+		}
+		if !strings.HasPrefix(perr.Error(), tt.path+": line ") {
+			t.Errorf("%s: error %q does not name the file and line", tt.path, perr)
+		}
+	}
+}
+
+func TestSpecName(t *testing.T) {
+	tests := map[string]string{
+		"-":                               "stdin",
+		"logs/density.log":                "density",
+		"logs/density.log.gz":             "density",
+		"bench-data.tar.gz:bench-new.txt": "bench-new",
+		"bench-data.tgz":                  "bench-data",
+	}
+	for spec, want := range tests {
+		if name := SpecName(spec); name != want {
+			t.Errorf("SpecName(%q) = %q, want %q", spec, name, want)
+		}
+	}
+}
+
+func TestParseLatency(t *testing.T) {
+	log := `Jun 17 00:10:00.000: INFO: 10% worst e2e total latencies: [{pod-1 node-1 1.5s}]
+Jun 17 00:10:00.000: INFO: perc50: 1.2s, perc90: 2.3s, perc99: 3.4s
+Jun 17 00:10:05.000: INFO: Pod startup latency: {
+  "latency": {
+    "Perc50": 1000000000,
+    "Perc90": 2000000000,
+    "Perc99": 3000000000
+  }
+}
+Jun 17 00:10:10.000: INFO: API calls latencies: {
+  "apicalls": [
+    {"resource": "pods", "verb": "LIST", "latency": {"Perc50": 1000000, "Perc90": 2000000, "Perc99": 300000000}}
+  ]
+}
+`
+	rs, _, err := mustLookup(t, "latency").Parse(strings.NewReader(log), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 3 {
+		t.Fatalf("%d records, want 3", len(rs))
+	}
+
+	s := SummarizeLatency(rs)
+	wl := map[string]Latency{
+		"e2e-total":   {Perc50: 1.2, Perc90: 2.3, Perc99: 3.4},
+		"pod-startup": {Perc50: 1, Perc90: 2, Perc99: 3},
+	}
+	if !reflect.DeepEqual(s.Latencies, wl) {
+		t.Errorf("latencies %v, want %v", s.Latencies, wl)
+	}
+	wa := []APICallLatency{{Verb: "LIST", Resource: "pods", Latency: Latency{Perc50: 0.001, Perc90: 0.002, Perc99: 0.3}}}
+	if !reflect.DeepEqual(s.APICalls, wa) {
+		t.Errorf("API calls %v, want %v", s.APICalls, wa)
+	}
+	if rs[2].Seconds != 10 {
+		t.Errorf("API calls at %v seconds, want 10", rs[2].Seconds)
+	}
+}
+
+func TestParseLatencyTruncated(t *testing.T) {
+	log := `Jun 17 00:10:00.000: INFO: Pod startup latency: {"latency": {"Perc50": 1000000000, "Perc90": 2000000000, "Perc99": 3000000000}}
+Jun 17 00:10:10.000: INFO: API calls latencies: {"apicalls": [
+{"resource": "pods", "verb": "LIST",`
+
+	lt := mustLookup(t, "latency")
+	rs, _, err := lt.Parse(strings.NewReader(log), DefaultOptions())
+	perr, ok := err.(*ParseError)
+	if !ok || perr.Line != 3 || !strings.Contains(perr.Error(), "API calls latencies block of 2 lines") {
+		t.Errorf("error %v, want one at line 3 about the API calls block", err)
+	}
+	if len(rs) != 1 {
+		t.Errorf("%d records, want 1", len(rs))
+	}
+
+	o := DefaultOptions()
+	o.Lenient = true
+	rs, bad, err := lt.Parse(strings.NewReader(log), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || len(bad) != 1 || bad[0].Line != 3 {
+		t.Errorf("lenient: %d records and bad lines %v, want 1 record and line 3", len(rs), bad)
+	}
+}
+
+func TestSmoothRates(t *testing.T) {
+	rs := []Record{
+		{Seconds: 0, Values: map[string]float64{DensityRunning: 0}},
+		{Seconds: 10, Values: map[string]float64{DensityRunning: 20}},
+		{Seconds: 10, Values: map[string]float64{DensityRunning: 20}},
+		{Seconds: 20, Values: map[string]float64{DensityRunning: 60}},
+		{Seconds: 30, Values: map[string]float64{DensityRunning: 60}},
+	}
+	pts := RateTimePoints(rs, DensityRunning)
+	var xs, ys []float64
+	for _, pt := range pts {
+		xs, ys = append(xs, pt.X), append(ys, pt.Y)
+	}
+	if w := []float64{10, 20, 30}; !reflect.DeepEqual(xs, w) {
+		t.Errorf("rate times %v, want %v", xs, w)
+	}
+	if w := []float64{2, 4, 0}; !reflect.DeepEqual(ys, w) {
+		t.Errorf("rates %v, want %v", ys, w)
+	}
+
+	tests := []struct {
+		s    Smoothing
+		want []float64
+	}{
+		{Smoothing{}, []float64{2, 4, 0}},
+		{Smoothing{Method: "ma", Window: 2}, []float64{2, 3, 2}},
+		{Smoothing{Method: "ewma", Alpha: 0.5}, []float64{2, 3, 1.5}},
+		{Smoothing{Method: "p50", Window: 3}, []float64{2, 2, 2}},
+	}
+	for _, tt := range tests {
+		if err := tt.s.Validate(); err != nil {
+			t.Errorf("%+v: %v", tt.s, err)
+			continue
+		}
+		var got []float64
+		for _, pt := range tt.s.Smooth(pts) {
+			got = append(got, pt.Y)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: smoothed %v, want %v", tt.s, got, tt.want)
+		}
+	}
+	if err := (Smoothing{Method: "median"}).Validate(); err == nil {
+		t.Error("unknown smoothing is valid")
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.Output.Dir = dir
+	svg := `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><text>%s</text></svg>`
+	// a graph of this run, and one left in the directory by another
+	for _, name := range []string{"density-all", "scheduler-bench-rate"} {
+		if err := ioutil.WriteFile(o.Output.ImagePath(name), []byte(fmt.Sprintf(svg, name)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rs := []Record{
+		{Seconds: 0, Values: map[string]float64{DensityRunning: 0, DensityTotal: 10}},
+		{Seconds: 5, Values: map[string]float64{DensityRunning: 10, DensityTotal: 10}},
+	}
+	meta := Metadata{MetadataGitSHA: "<abc123>"}
+	if err := mustLookup(t, "density").WriteHTMLReport([]Run{{Label: "run", Records: rs}}, meta,
+		[]string{o.Output.ImagePath("density-all")}, o); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(o.Output.Path(HTMLReportName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"><text>density-all</text></svg>`,
+		`<th>git SHA</th><td>&lt;abc123&gt;</td>`,
+		`<th>avgRunningRate</th><td class="num">1</td>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report does not contain %s:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<?xml") {
+		t.Error("report embeds the XML declaration of graphs")
+	}
+	if strings.Contains(html, "scheduler-bench-rate") {
+		t.Error("report embeds a graph of another run")
+	}
+}
+
+func TestTrend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/trend/trend.jsonl"
+
+	day := time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []TrendEntry{
+		{Build: "1", Type: "density", Metrics: map[string]float64{"avgRunningRate": 10}},
+		{Build: "2", Type: "density", Metrics: map[string]float64{"avgRunningRate": 20}},
+		{Build: "2", Type: "scheduler-bench", Metrics: map[string]float64{"throughput": 50}},
+		{Build: "2", Type: "density", Metrics: map[string]float64{"avgRunningRate": 21}},
+		{Build: "3", Type: "density", Metrics: map[string]float64{"avgRunningRate": 30, "secondsToAllRunning": 60}},
+	} {
+		e.Time = day.AddDate(0, 0, i)
+		if err := AppendTrend(path, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	all, err := ReadTrend(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("%d entries, want 5", len(all))
+	}
+
+	es := LastBuilds(all, "density", 2)
+	var got []float64
+	for _, e := range es {
+		got = append(got, e.Metrics["avgRunningRate"])
+	}
+	if w := []float64{21, 30}; !reflect.DeepEqual(got, w) {
+		t.Errorf("running rates of last builds %v, want %v", got, w)
+	}
+	if ms, w := TrendMetrics(es), []string{"avgRunningRate", "secondsToAllRunning"}; !reflect.DeepEqual(ms, w) {
+		t.Errorf("metrics %v, want %v", ms, w)
+	}
+}
+
+func TestPlotLatencyTrend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var es []TrendEntry
+	for i, perc50 := range []float64{1.5, 2} {
+		rs := []Record{{Values: map[string]float64{
+			latencyPodStartup + "/perc50": perc50,
+			latencyPodStartup + "/perc90": 3,
+			latencyPodStartup + "/perc99": 4,
+		}}}
+		e, err := NewTrendEntry("latency", strconv.Itoa(i+1), rs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		es = append(es, e)
+	}
+
+	key := `seconds{latency="pod-startup",quantile="0.5"}`
+	if es[1].Metrics[key] != 2 {
+		t.Fatalf("metrics %v, want %s", es[1].Metrics, key)
+	}
+	var graphs []string
+	o := DefaultOptions()
+	o.Output.Dir = dir
+	o.Graphs = &graphs
+	if err := PlotTrend(es, []string{key}, o); err != nil {
+		t.Fatal(err)
+	}
+	want := o.Output.ImagePath("trend-seconds_latency__pod-startup__quantile__0.5__")
+	if !reflect.DeepEqual(graphs, []string{want}) {
+		t.Errorf("graphs %v, want %v", graphs, []string{want})
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDetectRegressions(t *testing.T) {
+	run := func(label string, toRunning float64) Run {
+		return Run{Label: label, Records: []Record{
+			{Seconds: 0, Values: map[string]float64{DensityCreated: 30, DensityRunning: 0, DensityTotal: 30}},
+			{Seconds: toRunning, Values: map[string]float64{DensityCreated: 30, DensityRunning: 30, DensityTotal: 30}},
+		}}
+	}
+	lt := mustLookup(t, "density")
+	baseline := []Run{run("b1", 10), run("b2", 10.1)}
+	candidate := []Run{run("c1", 20), run("c2", 20.2)}
+
+	rs, err := lt.DetectRegressions(baseline, candidate, regression.Config{Threshold: 0.05, Alpha: 0.05})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rs {
+		got = append(got, fmt.Sprintf("%s %v", r.Metric, r.Regressed))
+	}
+	want := []string{"avgCreatingRate true", "avgRunningRate true", "secondsToAllRunning true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+
+	short := Run{Label: "short", Records: run("", 10).Records[:1]}
+	_, err = lt.DetectRegressions(baseline, append(candidate, short), regression.Config{})
+	if err == nil || !strings.Contains(err.Error(), "run short cannot be measured") {
+		t.Errorf("error %v, want one about the short run", err)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	rs := []Record{
+		{Seconds: 0, Values: map[string]float64{DensityCreated: 10, DensityRunning: 0, DensityTotal: 10}},
+		{Seconds: 5, Values: map[string]float64{DensityCreated: 10, DensityRunning: 10, DensityTotal: 10}},
+	}
+	var buf bytes.Buffer
+	meta := Metadata{MetadataGitSHA: `ab"c`, "build": "42"}
+	err := WritePrometheus(&buf, "density", []Run{{Label: "a", Records: rs}}, map[string]string{"project": "kubemark"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE kscale_density_run_info gauge
+kscale_density_run_info{build="42",git_sha="ab\"c",project="kubemark",run="a"} 1
+# TYPE kscale_density_avg_creating_rate gauge
+kscale_density_avg_creating_rate{project="kubemark",run="a"} 2
+# TYPE kscale_density_avg_running_rate gauge
+kscale_density_avg_running_rate{project="kubemark",run="a"} 1
+# TYPE kscale_density_seconds_to_all_running gauge
+kscale_density_seconds_to_all_running{project="kubemark",run="a"} 5
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// runs labeled alike would have duplicate samples
+	buf.Reset()
+	err = WritePrometheus(&buf, "density", []Run{{Label: "run", Records: rs}, {Label: "run", Records: rs}}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `several runs are labeled "run"`) {
+		t.Errorf("error %v, want one about the duplicate label", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q for runs labeled alike", buf.String())
+	}
+
+	rs = []Record{{Values: map[string]float64{"pod-startup/perc50": 1, "pod-startup/perc90": 2, "pod-startup/perc99": 3}}}
+	buf.Reset()
+	if err := WritePrometheus(&buf, "latency", []Run{{Records: rs}}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w := `kscale_latency_seconds{latency="pod-startup",quantile="0.99"} 3`; !strings.Contains(buf.String(), w) {
+		t.Errorf("latency metrics do not contain %s:\n%s", w, buf.String())
+	}
+}
@@ -0,0 +1,62 @@
+package logplot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gonum/plot/vg"
+)
+
+// ImageFormats lists the image formats supported by gonum's vg backends
+// that graphs can be saved as.
+var ImageFormats = []string{"svg", "png", "pdf", "eps"}
+
+// Output controls where and how reports are written.
+type Output struct {
+	// directory to write into
+	Dir string
+	// prepended to every file name
+	Prefix string
+	// image format of graphs, one of ImageFormats
+	Format string
+	// dimensions of graphs
+	Width, Height vg.Length
+}
+
+// DefaultOutput writes 10x10in SVGs into the current directory.
+func DefaultOutput() Output {
+	return Output{
+		Dir:    ".",
+		Format: "svg",
+		Width:  10 * vg.Inch,
+		Height: 10 * vg.Inch,
+	}
+}
+
+func (o Output) Validate() error {
+	for _, f := range ImageFormats {
+		if o.Format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported image format %q, supported formats: %v", o.Format, ImageFormats)
+}
+
+// Path returns the path of the output file with the given name.
+func (o Output) Path(name string) string {
+	return filepath.Join(o.Dir, o.Prefix+name)
+}
+
+// ImagePath returns the path of the graph with the given name.
+func (o Output) ImagePath(name string) string {
+	return o.Path(name + "." + o.Format)
+}
+
+// create creates the output file with the given name.
+func (o Output) create(name string) (*os.File, error) {
+	if err := os.MkdirAll(o.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(o.Path(name))
+}
@@ -0,0 +1,131 @@
+package logplot
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gonum/plot"
+	"github.com/gonum/plot/plotter"
+	"github.com/gonum/plot/plotutil"
+)
+
+// plotSeries plots the named series of rs against time and saves the
+// graph as the output image with the given name.
+func plotSeries(rs []Record, o Options, title, ylabel, name string, names ...string) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+
+	p.Title.Text = title
+	p.X.Label.Text = "Seconds"
+	p.Y.Label.Text = ylabel
+
+	var vs []interface{}
+	for _, n := range names {
+		vs = append(vs, strings.Title(n), Points(rs, n))
+	}
+	if err := plotutil.AddLinePoints(p, vs...); err != nil {
+		return err
+	}
+
+	return savePlot(p, o, name)
+}
+
+// plotRuns plots the named series of every run on shared axes and saves
+// the graph as the output image with the given name. points returns the
+// line of a named series.
+func plotRuns(runs []Run, o Options, title, xlabel, ylabel, name string, names []string,
+	points func(rs []Record, name string) plotter.XYs) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+
+	p.Title.Text = title
+	p.X.Label.Text = xlabel
+	p.Y.Label.Text = ylabel
+
+	var vs []interface{}
+	for _, r := range runs {
+		for _, n := range names {
+			legend := r.Label
+			if len(names) > 1 {
+				legend += " " + strings.Title(n)
+			}
+			vs = append(vs, legend, points(r.Records, n))
+		}
+	}
+	if err := plotutil.AddLinePoints(p, vs...); err != nil {
+		return err
+	}
+
+	return savePlot(p, o, name)
+}
+
+// savePlot saves p as the output image with the given name.
+func savePlot(p *plot.Plot, o Options, name string) error {
+	filename := o.Output.ImagePath(name)
+	if err := os.MkdirAll(o.Output.Dir, 0755); err != nil {
+		return err
+	}
+	if err := p.Save(o.Output.Width, o.Output.Height, filename); err != nil {
+		return err
+	}
+
+	if o.Graphs != nil {
+		*o.Graphs = append(*o.Graphs, filename)
+	}
+	o.logf("successfully plotted graph to %s", filename)
+	return nil
+}
+
+// Points returns the named series of rs against time. Records without
+// the series are left out.
+func Points(rs []Record, name string) plotter.XYs {
+	pts := make(plotter.XYs, 0, len(rs))
+
+	for i := range rs {
+		v, ok := rs[i].Values[name]
+		if !ok {
+			continue
+		}
+		pts = append(pts, plotter.XY{X: rs[i].Seconds, Y: v})
+	}
+	return pts
+}
+
+// RatePoints returns the per-second change of the named series between
+// consecutive records against the value of that series. Intervals in
+// which no time passed are left out.
+func RatePoints(rs []Record, name string) plotter.XYs {
+	return ratePoints(rs, name, func(r Record) float64 { return r.Values[name] })
+}
+
+// RateTimePoints returns the per-second change of the named series between
+// consecutive records against time. Intervals in which no time passed are
+// left out.
+func RateTimePoints(rs []Record, name string) plotter.XYs {
+	return ratePoints(rs, name, func(r Record) float64 { return r.Seconds })
+}
+
+func ratePoints(rs []Record, name string, x func(Record) float64) plotter.XYs {
+	var pts plotter.XYs
+
+	for i := 1; i < len(rs); i++ {
+		if rs[i].Seconds <= rs[i-1].Seconds {
+			continue
+		}
+		pts = append(pts, plotter.XY{X: x(rs[i]), Y: Rate(rs[i-1], rs[i], name)})
+	}
+	return pts
+}
+
+// Rate returns the per-second change of the named series from a to b.
+func Rate(a, b Record, name string) float64 {
+	d := b.Seconds - a.Seconds
+	if d <= 0 {
+		return 0
+	}
+	return (b.Values[name] - a.Values[name]) / d
+}
@@ -0,0 +1,165 @@
+package logplot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WritePrometheus writes the metrics of runs of the named log type in the
+// Prometheus text format, as gauges named kscale_<type>_<metric> in snake
+// case, e.g. kscale_density_avg_running_rate. Every sample is labeled by
+// the label of its run, if any, and by labels, which should not change
+// from build to build, e.g. the project, so that every build adds to the
+// same series. The metadata of the runs, e.g. the build number and git
+// SHA, is instead written as a kscale_<type>_run_info gauge of 1 per run,
+// labeled by the metadata too, which queries can join on. Runs must be
+// labeled apart, since their samples would be duplicates otherwise.
+func WritePrometheus(w io.Writer, typ string, runs []Run, labels map[string]string, meta Metadata) error {
+	lt, ok := Lookup(typ)
+	if !ok {
+		return fmt.Errorf("unknown log type %q", typ)
+	}
+	if lt.Metrics == nil {
+		return fmt.Errorf("log type %s has no metrics to export", typ)
+	}
+	seen := make(map[string]bool)
+	for _, r := range runs {
+		if seen[r.Label] {
+			return fmt.Errorf("several runs are labeled %q", r.Label)
+		}
+		seen[r.Label] = true
+	}
+
+	// runLabels returns labels, plus the run label of r, plus extra
+	runLabels := func(r Run, extra map[string]string) map[string]string {
+		ls := make(map[string]string)
+		for k, v := range labels {
+			ls[k] = v
+		}
+		if r.Label != "" {
+			ls["run"] = r.Label
+		}
+		for k, v := range extra {
+			ls[k] = v
+		}
+		return ls
+	}
+
+	// samples by metric family, in the order the log type reports them
+	var names []string
+	samples := make(map[string][]string)
+	add := func(name string, ls map[string]string, v float64) {
+		if _, ok := samples[name]; !ok {
+			names = append(names, name)
+		}
+		samples[name] = append(samples[name], name+promLabels(ls)+" "+strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	for _, r := range runs {
+		if len(meta) > 0 {
+			add(promName("kscale_"+typ+"_run_info"), runLabels(r, meta), 1)
+		}
+		for _, m := range lt.Metrics(r.Records) {
+			add(promName("kscale_"+typ+"_"+m.Name), runLabels(r, m.Labels), m.Value)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+		for _, s := range samples[name] {
+			fmt.Fprintln(&buf, s)
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// PushPrometheus pushes the metrics of runs of the named log type to a
+// Pushgateway-compatible endpoint at addr, e.g. http://localhost:9091,
+// replacing the metrics of the group of job and grouping labels. Samples
+// are labeled, and metadata written, as by WritePrometheus.
+func PushPrometheus(addr, job string, grouping map[string]string, typ string, runs []Run,
+	labels map[string]string, meta Metadata) error {
+	var body bytes.Buffer
+	if err := WritePrometheus(&body, typ, runs, labels, meta); err != nil {
+		return err
+	}
+
+	path := "/metrics/job/" + promEscapePath(job)
+	var keys []string
+	for k := range grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path += "/" + promName(k) + "/" + promEscapePath(grouping[k])
+	}
+
+	req, err := http.NewRequest("PUT", strings.TrimSuffix(addr, "/")+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("push to %s: %s: %s", addr, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// promName turns s into a valid Prometheus metric or label name in snake
+// case, e.g. kscale_scheduler-bench_secondsToComplete into
+// kscale_scheduler_bench_seconds_to_complete.
+func promName(s string) string {
+	var b bytes.Buffer
+	var prev rune
+	for i, r := range s {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsUpper(r):
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// promLabels formats sorted labels, e.g. {quantile="0.99",run="a"}.
+func promLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var ls []string
+	for k, v := range labels {
+		ls = append(ls, promName(k)+`="`+promLabelEscaper.Replace(v)+`"`)
+	}
+	sort.Strings(ls)
+	return "{" + strings.Join(ls, ",") + "}"
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promEscapePath escapes a job name or grouping label value for the path
+// of a push.
+func promEscapePath(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
@@ -0,0 +1,72 @@
+package logplot
+
+import (
+	"fmt"
+
+	"github.com/coreos/kscale/logplot/regression"
+)
+
+// DetectRegressions measures the baseline and the candidate runs, and
+// compares every metric of the baseline runs against the candidate runs,
+// in the order lt reports them. A run lt cannot measure, and a metric
+// missing from the candidate runs, are errors.
+func (lt LogType) DetectRegressions(baseline, candidate []Run, cfg regression.Config) ([]regression.Result, error) {
+	if lt.Metrics == nil {
+		return nil, fmt.Errorf("log type does not support regression detection")
+	}
+	bms, err := lt.measureRuns(baseline)
+	if err != nil {
+		return nil, err
+	}
+	cms, err := lt.measureRuns(candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []regression.Result
+	for _, bm := range bms {
+		cm := findMeasured(cms, bm.key)
+		if cm == nil {
+			return nil, fmt.Errorf("metric %s missing from candidate runs", bm.key)
+		}
+		rs = append(rs, regression.Compare(bm.key, bm.dir, bm.values, cm.values, cfg))
+	}
+	return rs, nil
+}
+
+// measuredMetric is the values of a metric over repeated runs.
+type measuredMetric struct {
+	key    string
+	dir    regression.Direction
+	values []float64
+}
+
+// measureRuns returns the values of every metric of runs, in the order lt
+// reports them.
+func (lt LogType) measureRuns(runs []Run) ([]*measuredMetric, error) {
+	var ms []*measuredMetric
+	for _, r := range runs {
+		runMetrics := lt.Metrics(r.Records)
+		if len(runMetrics) == 0 {
+			return nil, fmt.Errorf("run %s cannot be measured: it has %d records", r.Label, len(r.Records))
+		}
+		for _, m := range runMetrics {
+			mm := findMeasured(ms, m.Key())
+			if mm == nil {
+				mm = &measuredMetric{key: m.Key(), dir: m.Direction}
+				ms = append(ms, mm)
+			}
+			mm.values = append(mm.values, m.Value)
+		}
+	}
+	return ms, nil
+}
+
+func findMeasured(ms []*measuredMetric, key string) *measuredMetric {
+	for _, m := range ms {
+		if m.key == key {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package regression detects performance regressions between repeated
+// baseline and candidate benchmark runs.
+package regression
+
+import (
+	"fmt"
+	"math"
+)
+
+// Direction tells which way a metric improves.
+type Direction int
+
+const (
+	HigherIsBetter Direction = iota
+	LowerIsBetter
+)
+
+// ExitRegressed is the exit status of commands that find a regression.
+const ExitRegressed = 3
+
+// Config controls when a change counts as a regression.
+type Config struct {
+	// Threshold is the relative change, e.g. 0.05 for 5%, by which the
+	// candidate must be worse than the baseline to regress.
+	Threshold float64
+	// Alpha is the significance level the change must reach when both
+	// sides have at least two runs.
+	Alpha float64
+}
+
+// Result is the comparison of one metric between baseline and candidate.
+type Result struct {
+	Metric    string
+	Direction Direction
+
+	BaselineMean  float64
+	CandidateMean float64
+	// Delta is the relative change of the candidate mean from the
+	// baseline mean.
+	Delta float64
+	// P is the two-sided p-value of Welch's t-test, or NaN if either side
+	// has fewer than two runs.
+	P float64
+
+	Regressed bool
+}
+
+// Compare compares the values of a metric measured over repeated baseline
+// and candidate runs. The candidate regresses if it is worse than the
+// baseline by more than cfg.Threshold and, when there are enough runs to
+// tell, the difference is significant at cfg.Alpha.
+func Compare(metric string, dir Direction, baseline, candidate []float64, cfg Config) Result {
+	r := Result{
+		Metric:        metric,
+		Direction:     dir,
+		BaselineMean:  mean(baseline),
+		CandidateMean: mean(candidate),
+		P:             welchP(baseline, candidate),
+	}
+	if r.BaselineMean != 0 {
+		r.Delta = (r.CandidateMean - r.BaselineMean) / math.Abs(r.BaselineMean)
+	}
+
+	worse := r.Delta
+	if dir == HigherIsBetter {
+		worse = -worse
+	}
+	r.Regressed = worse > cfg.Threshold && (math.IsNaN(r.P) || r.P < cfg.Alpha)
+	return r
+}
+
+func (r Result) String() string {
+	verdict := "ok"
+	if r.Regressed {
+		verdict = "REGRESSED"
+	}
+	return fmt.Sprintf("%-24s baseline: %12.4f  candidate: %12.4f  delta: %+7.2f%%  p: %6.4f  %s",
+		r.Metric, r.BaselineMean, r.CandidateMean, r.Delta*100, r.P, verdict)
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// variance returns the unbiased sample variance of xs.
+func variance(xs []float64) float64 {
+	m := mean(xs)
+	var sum float64
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	return sum / float64(len(xs)-1)
+}
+
+// welchP returns the two-sided p-value of Welch's t-test for the means
+// of a and b.
+func welchP(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return math.NaN()
+	}
+	na, nb := float64(len(a)), float64(len(b))
+	va, vb := variance(a)/na, variance(b)/nb
+	if va+vb == 0 {
+		if mean(a) == mean(b) {
+			return 1
+		}
+		return 0
+	}
+	t := (mean(a) - mean(b)) / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/(na-1) + vb*vb/(nb-1))
+	// P(|T| > |t|) for Student's t distribution with df degrees of freedom
+	return regIncBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// regIncBeta returns the regularized incomplete beta function I_x(a, b).
+func regIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// the continued fraction converges quickly for x < (a+1)/(a+b+2)
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF evaluates the continued fraction of the incomplete beta function
+// by the modified Lentz's method.
+func betaCF(a, b, x float64) float64 {
+	const (
+		maxIter = 200
+		eps     = 1e-14
+		tiny    = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		for i := 0; i < 2; i++ {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+			num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		}
+		if math.Abs(d*c-1) < eps {
+			break
+		}
+	}
+	return h
+}
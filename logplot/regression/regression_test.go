@@ -0,0 +1,96 @@
+package regression
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegIncBeta(t *testing.T) {
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		{2, 3, 0, 0},
+		{2, 3, 1, 1},
+		// I_x(a, 1) = x^a
+		{2.5, 1, 0.3, math.Pow(0.3, 2.5)},
+		// I_x(1, b) = 1 - (1-x)^b
+		{1, 4, 0.2, 1 - math.Pow(0.8, 4)},
+		// I_0.5(a, a) = 0.5 by symmetry
+		{7, 7, 0.5, 0.5},
+		{0.5, 0.5, 0.5, 0.5},
+	}
+	for _, tt := range tests {
+		if got := regIncBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("regIncBeta(%v, %v, %v) = %v, want %v", tt.a, tt.b, tt.x, got, tt.want)
+		}
+	}
+}
+
+// TestWelchP checks p-values against those of numerically integrating
+// the density of Student's t distribution.
+func TestWelchP(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"equal variances", []float64{1, 2, 3}, []float64{4, 5, 6}, 0.0213116411},
+		{"wikipedia example",
+			[]float64{27.5, 21.0, 19.0, 23.6, 17.0, 17.9, 16.9, 20.1, 21.9, 22.6, 23.1, 19.6, 19.0, 21.7, 21.4},
+			[]float64{27.1, 22.0, 20.8, 23.4, 23.4, 23.5, 25.8, 22.0, 24.8, 20.2, 21.9, 22.1, 22.9, 20.5, 24.4},
+			0.0213780015},
+		{"unequal variances and sizes", []float64{10, 12, 11, 13}, []float64{20, 15, 30}, 0.1448383348},
+		{"close means", []float64{100, 101, 99, 100.5}, []float64{100.2, 99.8, 100.9, 99.5}, 0.9636188383},
+		{"no variance, same mean", []float64{5, 5}, []float64{5, 5, 5}, 1},
+		{"no variance, different means", []float64{5, 5}, []float64{6, 6}, 0},
+	}
+	for _, tt := range tests {
+		if got := welchP(tt.a, tt.b); math.Abs(got-tt.want) > 1e-8 {
+			t.Errorf("%s: p = %.10f, want %.10f", tt.name, got, tt.want)
+		}
+		if got := welchP(tt.b, tt.a); math.Abs(got-tt.want) > 1e-8 {
+			t.Errorf("%s swapped: p = %.10f, want %.10f", tt.name, got, tt.want)
+		}
+	}
+
+	for _, short := range [][]float64{nil, {1}} {
+		if p := welchP(short, []float64{1, 2}); !math.IsNaN(p) {
+			t.Errorf("p of %v = %v, want NaN", short, p)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cfg := Config{Threshold: 0.05, Alpha: 0.05}
+	tests := []struct {
+		name                string
+		dir                 Direction
+		baseline, candidate []float64
+		regressed           bool
+	}{
+		// single runs regress on the threshold alone
+		{"higher, at threshold", HigherIsBetter, []float64{100}, []float64{95}, false},
+		{"higher, past threshold", HigherIsBetter, []float64{100}, []float64{94.9}, true},
+		{"higher, improved", HigherIsBetter, []float64{100}, []float64{150}, false},
+		{"lower, at threshold", LowerIsBetter, []float64{100}, []float64{105}, false},
+		{"lower, past threshold", LowerIsBetter, []float64{100}, []float64{105.1}, true},
+		{"lower, improved", LowerIsBetter, []float64{100}, []float64{50}, false},
+
+		// repeated runs must also differ significantly
+		{"significant, past threshold", HigherIsBetter,
+			[]float64{100, 101, 99, 100}, []float64{90, 91, 89, 90}, true},
+		{"significant, within threshold", HigherIsBetter,
+			[]float64{100, 100.1, 99.9, 100}, []float64{98, 98.1, 97.9, 98}, false},
+		{"past threshold, not significant", HigherIsBetter,
+			[]float64{100, 140, 60, 100}, []float64{90, 130, 50, 85}, false},
+		{"lower, significant, past threshold", LowerIsBetter,
+			[]float64{90, 91, 89, 90}, []float64{100, 101, 99, 100}, true},
+	}
+	for _, tt := range tests {
+		r := Compare("m", tt.dir, tt.baseline, tt.candidate, cfg)
+		if r.Regressed != tt.regressed {
+			t.Errorf("%s: regressed %v, want %v (delta %v, p %v)", tt.name, r.Regressed, tt.regressed, r.Delta, r.P)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package logplot
+
+import (
+	"fmt"
+
+	"github.com/coreos/kscale/logplot/regression"
+)
+
+// series reported by scheduler benchmark logs
+const (
+	SchedBenchRate  = "rate"
+	SchedBenchTotal = "total"
+)
+
+func init() {
+	Register("scheduler-bench", LogType{
+		NewParser: func(Options) LineParser { return SchedBenchParser{} },
+		Report:    ReportSchedBench,
+		Compare:   CompareSchedBench,
+		Metrics:   SchedBenchMetrics,
+	})
+}
+
+func ReportSchedBench(rs []Record, o Options) error {
+	if err := plotSeries(rs, o, "Scheduler Benchmark", "Number of Pods", "scheduler-bench-total", SchedBenchTotal); err != nil {
+		return err
+	}
+	return plotSeries(rs, o, "Scheduler Benchmark", "Rate of Scheduling", "scheduler-bench-rate", SchedBenchRate)
+}
+
+func CompareSchedBench(runs []Run, o Options) error {
+	if err := plotRuns(runs, o, "Scheduler Benchmark", "Seconds", "Number of Pods", "scheduler-bench-compare-total",
+		[]string{SchedBenchTotal}, Points); err != nil {
+		return err
+	}
+	return plotRuns(runs, o, "Scheduler Benchmark", "Seconds", "Rate of Scheduling", "scheduler-bench-compare-rate",
+		[]string{SchedBenchRate}, Points)
+}
+
+// SchedBenchMetrics measures the scheduling throughput of a benchmark run
+// and how long it took to schedule all pods. A run of fewer than two
+// records cannot be measured and has no metrics.
+func SchedBenchMetrics(rs []Record) []Metric {
+	if len(rs) < 2 {
+		return nil
+	}
+	last := rs[len(rs)-1]
+	done := last
+	for _, r := range rs {
+		if r.Values[SchedBenchTotal] >= last.Values[SchedBenchTotal] {
+			done = r
+			break
+		}
+	}
+	return []Metric{
+		{Name: "throughput", Value: Rate(rs[0], done, SchedBenchTotal), Direction: regression.HigherIsBetter},
+		{Name: "secondsToComplete", Value: done.Seconds - rs[0].Seconds, Direction: regression.LowerIsBetter},
+	}
+}
+
+// SchedBenchParser parses the output of the scheduler benchmark.
+type SchedBenchParser struct{}
+
+// wanted format:
+// 4s	rate: 28	total: 29
+func (SchedBenchParser) ParseLine(line string) (Record, bool, error) {
+	schedBenchFormat := "%ds\trate: %d\ttotal: %d"
+
+	var seconds, rate, total int
+
+	if line == "" {
+		return Record{}, false, nil
+	}
+
+	_, err := fmt.Sscanf(line, schedBenchFormat, &seconds, &rate, &total)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("bad scheduler benchmark format: %v", err)
+	}
+
+	return Record{
+		Seconds: float64(seconds),
+		Values: map[string]float64{
+			SchedBenchRate:  float64(rate),
+			SchedBenchTotal: float64(total),
+		},
+	}, true, nil
+}
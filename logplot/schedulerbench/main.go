@@ -1,101 +1,174 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 
+	"github.com/coreos/kscale/logplot"
+	"github.com/coreos/kscale/logplot/regression"
 	"github.com/gonum/plot"
-	"github.com/gonum/plot/plotter"
 	"github.com/gonum/plot/plotutil"
 	"github.com/gonum/plot/vg"
 )
 
-type result struct {
-	// x axis
-	time int
-
-	// y axis
-	rate  int
-	total int
-}
+// output controls where and how graphs are written.
+var output = logplot.Output{Dir: ".", Format: "png"}
 
 func main() {
-	nfpath := flag.String("nf", "bench-new.txt", "data file path")
-	ofpath := flag.String("of", "bench-old.txt", "data file path")
+	nfpath := flag.String("nf", "bench-new.txt", "data file path, labeled new, used when no inputs are given")
+	ofpath := flag.String("of", "bench-old.txt", "data file path, labeled old, used when no inputs are given")
 	ftype := flag.String("t", "total", "rate, total")
+	flag.StringVar(&output.Dir, "o", output.Dir, "output directory")
+	flag.StringVar(&output.Prefix, "prefix", output.Prefix, "prefix of output file names")
+	flag.StringVar(&output.Format, "format", output.Format,
+		"image format of graphs: "+strings.Join(logplot.ImageFormats, ", "))
+	width := flag.Float64("width", 10, "width of graphs in inches")
+	height := flag.Float64("height", 10, "height of graphs in inches")
+	var baseline, candidate logplot.Globs
+	flag.Var(&baseline, "baseline", "glob of baseline runs, which may match tar archive members as archive.tar.gz:pattern; "+
+		"with -candidate, checks for regressions instead of plotting")
+	flag.Var(&candidate, "candidate", "glob of candidate runs to check against -baseline")
+	var regCfg regression.Config
+	flag.Float64Var(&regCfg.Threshold, "threshold", 0.05, "relative change by which the candidate must be worse to regress")
+	flag.Float64Var(&regCfg.Alpha, "alpha", 0.05, "significance level of regressions across repeated runs")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [label=]path-or-glob...\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "A path may be - for stdin, a .gz file, or a tar archive member as archive.tar.gz:member.")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	nf, err := os.Open(*nfpath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open file: %s, err: %v\n", *nfpath, err)
-		os.Exit(1)
-	}
-	of, err := os.Open(*ofpath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open file: %s, err: %v\n", *ofpath, err)
+	output.Width = vg.Length(*width) * vg.Inch
+	output.Height = vg.Length(*height) * vg.Inch
+	if err := output.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	rs1, rs2 := parseDensity(nf, of)
-	plotDensity(rs1, rs2, *ftype)
-}
-
-func parseDensity(nr, or io.Reader) (res1, res2 []result) {
-	densityFormat := "%ds\trate: %d\ttotal: %d"
+	if len(baseline) > 0 || len(candidate) > 0 {
+		if len(baseline) == 0 || len(candidate) == 0 {
+			fmt.Fprintln(os.Stderr, "Both -baseline and -candidate are needed to check for regressions.")
+			os.Exit(1)
+		}
+		if checkRegression(baseline, candidate, regCfg) {
+			os.Exit(regression.ExitRegressed)
+		}
+		return
+	}
 
-	nbr := bufio.NewReader(nr)
-	obr := bufio.NewReader(or)
-	for {
-		var r1 result
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"new=" + *nfpath, "old=" + *ofpath}
+	}
 
-		bytes, err := nbr.ReadBytes('\n')
+	var runs []logplot.Run
+	for _, arg := range args {
+		inputs, err := expandInput(arg)
 		if err != nil {
-			if err != io.EOF {
-				panic(err)
-			}
-			break
+			fmt.Fprintf(os.Stderr, "Bad input %s: %v\n", arg, err)
+			os.Exit(1)
 		}
+		for _, in := range inputs {
+			runs = append(runs, logplot.Run{Label: in.label, Records: parseBench(in.path)})
+		}
+	}
 
-		line := strings.TrimSpace(string(bytes[:len(bytes)-1]))
+	plotBench(runs, *ftype)
+}
 
-		_, err = fmt.Sscanf(line, densityFormat, &r1.time, &r1.rate, &r1.total)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Bad density format:", err)
-			os.Exit(1)
+// checkRegression compares the metrics of the baseline runs against the
+// candidate runs, prints the comparison and reports whether the candidate
+// regressed.
+func checkRegression(baseline, candidate logplot.Globs, cfg regression.Config) bool {
+	rs, err := benchType.DetectRegressions(parseRuns(baseline), parseRuns(candidate), cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	regressed := false
+	for _, r := range rs {
+		fmt.Println(r)
+		if r.Regressed {
+			regressed = true
 		}
+	}
+	return regressed
+}
 
-		res1 = append(res1, r1)
+// parseRuns parses every benchmark output matching patterns into a run
+// labeled by its input spec.
+func parseRuns(patterns logplot.Globs) []logplot.Run {
+	specs, err := patterns.Specs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find runs: %v\n", err)
+		os.Exit(1)
+	}
+	var runs []logplot.Run
+	for _, spec := range specs {
+		runs = append(runs, logplot.Run{Label: spec, Records: parseBench(spec)})
 	}
+	return runs
+}
 
-	for {
-		var r2 result
+// input is a labeled benchmark output file.
+type input struct {
+	label, path string
+}
 
-		bytes, err := obr.ReadBytes('\n')
-		if err != nil {
-			if err != io.EOF {
-				panic(err)
-			}
-			break
-		}
+// expandInput expands an argument of the form [label=]pattern into the
+// files matching the glob pattern. The pattern may also be - for stdin,
+// name .gz files, or match tar archive members as archive.tar.gz:pattern.
+// Files are labeled by the given label, suffixed by their base name if
+// the pattern matches several files, or by their base name alone if no
+// label is given.
+func expandInput(arg string) ([]input, error) {
+	var label string
+	pattern := arg
+	if i := strings.Index(arg, "="); i != -1 {
+		label, pattern = arg[:i], arg[i+1:]
+	}
 
-		line := strings.TrimSpace(string(bytes[:len(bytes)-1]))
+	paths, err := logplot.Expand(pattern)
+	if err != nil {
+		return nil, err
+	}
 
-		_, err = fmt.Sscanf(line, densityFormat, &r2.time, &r2.rate, &r2.total)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Bad density format:", err)
-			os.Exit(1)
+	var ins []input
+	for _, p := range paths {
+		base := logplot.SpecName(p)
+		in := input{label: label, path: p}
+		switch {
+		case label == "":
+			in.label = base
+		case len(paths) > 1:
+			in.label = label + "-" + base
 		}
+		ins = append(ins, in)
+	}
+	return ins, nil
+}
 
-		res2 = append(res2, r2)
+// benchType is the log type of scheduler benchmark output.
+var benchType, _ = logplot.Lookup("scheduler-bench")
+
+// parseBench parses the benchmark output named by the input spec path,
+// exiting on failure.
+func parseBench(path string) []logplot.Record {
+	rs, _, err := benchType.ParseFile(path, logplot.DefaultOptions())
+	if perr, ok := err.(*logplot.ParseError); ok {
+		fmt.Fprintf(os.Stderr, "Failed to parse %v\n", perr)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open file: %s, err: %v\n", path, err)
+		os.Exit(1)
 	}
-	return
+	return rs
 }
 
-func plotDensity(rs1, rs2 []result, ftype string) {
+func plotBench(runs []logplot.Run, ftype string) {
 	p, err := plot.New()
 	if err != nil {
 		panic(err)
@@ -104,48 +177,38 @@ func plotDensity(rs1, rs2 []result, ftype string) {
 	p.Title.Text = "Scheduler Benchmark"
 	p.X.Label.Text = "Seconds"
 
-	var filename string
+	var (
+		filename string
+		vs       []interface{}
+	)
 	switch ftype {
 	case "total":
 		p.Y.Label.Text = "Number of Pods"
-		err = plotutil.AddLinePoints(p,
-			"Total-new", getTotal(rs1),
-			"Total-old", getTotal(rs2))
-		filename = "schedule-total.png"
+		for _, r := range runs {
+			vs = append(vs, "Total-"+r.Label, logplot.Points(r.Records, logplot.SchedBenchTotal))
+		}
+		filename = "schedule-total"
 	case "rate":
 		p.Y.Label.Text = "Rate of Scheduling"
-		err = plotutil.AddLinePoints(p,
-			"Rate-new", getRate(rs1),
-			"Rate-old", getRate(rs2))
-		filename = "schedule-rate.png"
+		for _, r := range runs {
+			vs = append(vs, "Rate-"+r.Label, logplot.Points(r.Records, logplot.SchedBenchRate))
+		}
+		filename = "schedule-rate"
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported type: %s. Supported types: rate, total.\n", ftype)
+		os.Exit(1)
 	}
-	if err != nil {
+	if err := plotutil.AddLinePoints(p, vs...); err != nil {
 		panic(err)
 	}
 
-	if err := p.Save(10*vg.Inch, 10*vg.Inch, filename); err != nil {
+	if err := os.MkdirAll(output.Dir, 0755); err != nil {
 		panic(err)
 	}
-
-	fmt.Println("successfully plotted density graph to", filename)
-}
-
-func getRate(rs []result) plotter.XYs {
-	pts := make(plotter.XYs, len(rs))
-
-	for i := range rs {
-		pts[i].X = float64(rs[i].time)
-		pts[i].Y = float64(rs[i].rate)
+	filename = output.ImagePath(filename)
+	if err := p.Save(output.Width, output.Height, filename); err != nil {
+		panic(err)
 	}
-	return pts
-}
 
-func getTotal(rs []result) plotter.XYs {
-	pts := make(plotter.XYs, len(rs))
-
-	for i := range rs {
-		pts[i].X = float64(rs[i].time)
-		pts[i].Y = float64(rs[i].total)
-	}
-	return pts
+	fmt.Println("successfully plotted density graph to", filename)
 }
@@ -0,0 +1,105 @@
+package logplot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gonum/plot/plotter"
+)
+
+// Smoothing smooths noisy rate lines before they are plotted.
+type Smoothing struct {
+	// Method is one of
+	//	""      no smoothing
+	//	"ma"    moving average over Window points
+	//	"ewma"  exponentially weighted moving average with weight Alpha
+	//	"pNN"   moving NNth percentile over Window points, e.g. "p50"
+	Method string
+	Window int
+	Alpha  float64
+}
+
+func (s Smoothing) Validate() error {
+	switch {
+	case s.Method == "" || s.Method == "none":
+		return nil
+	case s.Method == "ewma":
+		if s.Alpha <= 0 || s.Alpha > 1 {
+			return fmt.Errorf("ewma weight must be in (0, 1], got %v", s.Alpha)
+		}
+		return nil
+	case s.Method == "ma" || strings.HasPrefix(s.Method, "p"):
+		if _, ok := s.percentile(); s.Method != "ma" && !ok {
+			return fmt.Errorf("unsupported smoothing %q", s.Method)
+		}
+		if s.Window < 1 {
+			return fmt.Errorf("smoothing window must be at least 1, got %d", s.Window)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported smoothing %q", s.Method)
+}
+
+// percentile returns the percentile of a "pNN" method.
+func (s Smoothing) percentile() (float64, bool) {
+	if !strings.HasPrefix(s.Method, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(s.Method[1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, false
+	}
+	return p, true
+}
+
+// Smooth returns pts with their Y values smoothed, in order of pts.
+func (s Smoothing) Smooth(pts plotter.XYs) plotter.XYs {
+	out := make(plotter.XYs, len(pts))
+	copy(out, pts)
+
+	switch {
+	case s.Method == "ma":
+		var sum float64
+		for i := range pts {
+			sum += pts[i].Y
+			n := i + 1
+			if i >= s.Window {
+				sum -= pts[i-s.Window].Y
+				n = s.Window
+			}
+			out[i].Y = sum / float64(n)
+		}
+	case s.Method == "ewma":
+		for i := range pts {
+			if i > 0 {
+				out[i].Y = s.Alpha*pts[i].Y + (1-s.Alpha)*out[i-1].Y
+			}
+		}
+	default:
+		p, ok := s.percentile()
+		if !ok {
+			break
+		}
+		for i := range pts {
+			var window []float64
+			for j := i - s.Window + 1; j <= i; j++ {
+				if j >= 0 {
+					window = append(window, pts[j].Y)
+				}
+			}
+			sort.Float64s(window)
+			out[i].Y = percentile(window, p)
+		}
+	}
+	return out
+}
+
+// points returns a line function for plotRuns that smooths the lines of
+// points.
+func (s Smoothing) points(points func(rs []Record, name string) plotter.XYs) func([]Record, string) plotter.XYs {
+	return func(rs []Record, name string) plotter.XYs {
+		return s.Smooth(points(rs, name))
+	}
+}
@@ -0,0 +1,228 @@
+package logplot
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// DensitySummary is the machine-readable summary of a density run.
+type DensitySummary struct {
+	TotalPods int `json:"totalPods"`
+
+	// seconds from the first density line until all pods were
+	// created or running; nil if that never happened
+	SecondsToAllCreated *float64 `json:"secondsToAllCreated"`
+	SecondsToAllRunning *float64 `json:"secondsToAllRunning"`
+
+	CreatingRate RateSummary `json:"creatingRate"`
+	RunningRate  RateSummary `json:"runningRate"`
+
+	Series []Sample `json:"series"`
+}
+
+// RateSummary summarizes the per-interval rates of a series, in pods
+// per second.
+type RateSummary struct {
+	Avg  float64 `json:"avg"`
+	Peak float64 `json:"peak"`
+	P50  float64 `json:"p50"`
+	P99  float64 `json:"p99"`
+}
+
+// Sample is the JSON form of a record.
+type Sample struct {
+	Seconds float64            `json:"seconds"`
+	Values  map[string]float64 `json:"values"`
+}
+
+func SummarizeDensity(rs []Record) DensitySummary {
+	s := DensitySummary{
+		TotalPods:           totalPods(rs),
+		SecondsToAllCreated: secondsToAll(rs, DensityCreated),
+		SecondsToAllRunning: secondsToAll(rs, DensityRunning),
+		CreatingRate:        summarizeRate(rs, DensityCreated),
+		RunningRate:         summarizeRate(rs, DensityRunning),
+	}
+	for _, r := range rs {
+		s.Series = append(s.Series, Sample{Seconds: r.Seconds, Values: r.Values})
+	}
+	return s
+}
+
+// secondsToAll returns the seconds of the first record at which the named
+// series reached the total number of pods.
+func secondsToAll(rs []Record, name string) *float64 {
+	total := float64(totalPods(rs))
+	for _, r := range rs {
+		if r.Values[name] >= total {
+			secs := r.Seconds
+			return &secs
+		}
+	}
+	return nil
+}
+
+func summarizeRate(rs []Record, name string) RateSummary {
+	var s RateSummary
+	if len(rs) == 0 {
+		return s
+	}
+
+	total := float64(totalPods(rs))
+	end := len(rs) - 1
+	for i := range rs {
+		if rs[i].Values[name] >= total {
+			end = i
+			break
+		}
+	}
+	s.Avg = avgRate(rs, end, name)
+
+	var rates []float64
+	for i := 1; i < len(rs); i++ {
+		// lines logged at the same time have no rate between them
+		if rs[i].Seconds <= rs[i-1].Seconds {
+			continue
+		}
+		rates = append(rates, Rate(rs[i-1], rs[i], name))
+	}
+	sort.Float64s(rates)
+	if len(rates) > 0 {
+		s.Peak = rates[len(rates)-1]
+	}
+	s.P50 = percentile(rates, 50)
+	s.P99 = percentile(rates, 99)
+	return s
+}
+
+// avgRate returns the pods of the named series at record end divided by
+// the seconds since one interval before the first record, as if none were
+// there then. The interval is the one between the first two records, so a
+// single record has no rate.
+func avgRate(rs []Record, end int, name string) float64 {
+	if len(rs) < 2 {
+		return 0
+	}
+	d := rs[end].Seconds - rs[0].Seconds + rs[1].Seconds - rs[0].Seconds
+	if d <= 0 {
+		return 0
+	}
+	return rs[end].Values[name] / d
+}
+
+// percentile returns the nearest-rank pth percentile of the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func recordDensitySummary(rs []Record, o Options) error {
+	s := SummarizeDensity(rs)
+	if err := writeJSON(s, o, "density-summary.json"); err != nil {
+		return err
+	}
+	if err := writeCSVSummary(s, o, "density-summary.csv"); err != nil {
+		return err
+	}
+	return writeCSVSeries(rs, o, "density-series.csv", append(DensityPhases, DensityTotal))
+}
+
+// writeJSON writes v as indented JSON to the output file with the given name.
+func writeJSON(v interface{}, o Options, name string) error {
+	filename := o.Output.Path(name)
+	f, err := o.Output.create(name)
+	if err != nil {
+		return fmt.Errorf("cannot create '%s' file: %v", filename, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write to '%s': %v", filename, err)
+	}
+	o.logf("successfully write summary to %s", filename)
+	return nil
+}
+
+func writeCSVSummary(s DensitySummary, o Options, name string) error {
+	rows := [][]string{
+		{"metric", "value"},
+		{"totalPods", strconv.Itoa(s.TotalPods)},
+		{"secondsToAllCreated", formatOptional(s.SecondsToAllCreated)},
+		{"secondsToAllRunning", formatOptional(s.SecondsToAllRunning)},
+	}
+	for _, rs := range []struct {
+		name string
+		s    RateSummary
+	}{
+		{"creatingRate", s.CreatingRate},
+		{"runningRate", s.RunningRate},
+	} {
+		rows = append(rows,
+			[]string{rs.name + "Avg", formatFloat(rs.s.Avg)},
+			[]string{rs.name + "Peak", formatFloat(rs.s.Peak)},
+			[]string{rs.name + "P50", formatFloat(rs.s.P50)},
+			[]string{rs.name + "P99", formatFloat(rs.s.P99)})
+	}
+	if err := writeCSV(rows, o, name); err != nil {
+		return err
+	}
+	o.logf("successfully write density summary to %s", o.Output.Path(name))
+	return nil
+}
+
+// writeCSVSeries writes one row per record with the seconds followed by
+// the named series.
+func writeCSVSeries(rs []Record, o Options, name string, names []string) error {
+	rows := [][]string{append([]string{"seconds"}, names...)}
+	for _, r := range rs {
+		row := []string{formatFloat(r.Seconds)}
+		for _, n := range names {
+			row = append(row, formatFloat(r.Values[n]))
+		}
+		rows = append(rows, row)
+	}
+	if err := writeCSV(rows, o, name); err != nil {
+		return err
+	}
+	o.logf("successfully write series to %s", o.Output.Path(name))
+	return nil
+}
+
+func writeCSV(rows [][]string, o Options, name string) error {
+	filename := o.Output.Path(name)
+	f, err := o.Output.create(name)
+	if err != nil {
+		return fmt.Errorf("cannot create '%s' file: %v", filename, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write to '%s': %v", filename, err)
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatOptional(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
@@ -0,0 +1,202 @@
+package logplot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonum/plot"
+	"github.com/gonum/plot/plotter"
+	"github.com/gonum/plot/plotutil"
+)
+
+// TrendEntry is the summary of one run in a trend store.
+type TrendEntry struct {
+	// when the run was recorded
+	Time time.Time `json:"time"`
+	// build number or other ID of the run; a later entry of the same log
+	// type and build replaces an earlier one unless the build is empty
+	Build string `json:"build"`
+	// registered name of the log type of the run
+	Type     string             `json:"type"`
+	Metadata Metadata           `json:"metadata,omitempty"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+// NewTrendEntry summarizes the records of a run of the named log type.
+// It fails if the log type does not measure runs, or cannot measure this
+// one.
+func NewTrendEntry(typ, build string, rs []Record, meta Metadata) (TrendEntry, error) {
+	lt, ok := Lookup(typ)
+	if !ok {
+		return TrendEntry{}, fmt.Errorf("unknown log type %q", typ)
+	}
+	if lt.Metrics == nil {
+		return TrendEntry{}, fmt.Errorf("log type %s has no metrics to record", typ)
+	}
+	e := TrendEntry{
+		Time:     time.Now().UTC(),
+		Build:    build,
+		Type:     typ,
+		Metadata: meta,
+		Metrics:  make(map[string]float64),
+	}
+	ms := lt.Metrics(rs)
+	if len(ms) == 0 {
+		return TrendEntry{}, fmt.Errorf("%s run of %d records cannot be measured", typ, len(rs))
+	}
+	for _, m := range ms {
+		e.Metrics[m.Key()] = m.Value
+	}
+	return e, nil
+}
+
+// AppendTrend appends e to the trend store at path, a file of JSON
+// lines, creating the file if needed.
+func AppendTrend(path string, e TrendEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadTrend reads the entries of a trend store in the order they were
+// appended.
+func ReadTrend(r io.Reader) ([]TrendEntry, error) {
+	var es []TrendEntry
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; s.Scan(); n++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var e TrendEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		es = append(es, e)
+	}
+	return es, s.Err()
+}
+
+// LastBuilds returns the entries of the last n builds of the named log
+// type, oldest first. Of several entries of a build, the last one is
+// kept. n <= 0 returns all builds.
+func LastBuilds(es []TrendEntry, typ string, n int) []TrendEntry {
+	var out []TrendEntry
+	index := make(map[string]int)
+	for _, e := range es {
+		if e.Type != typ {
+			continue
+		}
+		if i, ok := index[e.Build]; ok && e.Build != "" {
+			out[i] = e
+			continue
+		}
+		index[e.Build] = len(out)
+		out = append(out, e)
+	}
+	sort.Stable(byTime(out))
+	if n > 0 && len(out) > n {
+		out = out[len(out)-n:]
+	}
+	return out
+}
+
+type byTime []TrendEntry
+
+func (es byTime) Len() int           { return len(es) }
+func (es byTime) Less(i, j int) bool { return es[i].Time.Before(es[j].Time) }
+func (es byTime) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+
+// TrendMetrics returns the sorted names of the metrics of es.
+func TrendMetrics(es []TrendEntry) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range es {
+		for name := range e.Metrics {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// trendSlug turns a metric key, which may hold labels such as
+// seconds{latency="pod-startup",quantile="0.5"}, into a file name.
+func trendSlug(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9',
+			r == '_', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// PlotTrend plots each named metric over the builds of es, as the
+// output image trend-<slug>, where the slug is the metric key with every
+// character outside [A-Za-z0-9_.-] replaced by an underscore. Builds
+// without the metric are left out.
+func PlotTrend(es []TrendEntry, metrics []string, o Options) error {
+	var builds []string
+	for i, e := range es {
+		b := e.Build
+		if b == "" {
+			b = strconv.Itoa(i)
+		}
+		builds = append(builds, b)
+	}
+
+	for _, name := range metrics {
+		p, err := plot.New()
+		if err != nil {
+			return err
+		}
+
+		p.Title.Text = "Trend: " + name
+		p.X.Label.Text = "Build"
+		p.Y.Label.Text = name
+		p.NominalX(builds...)
+
+		var pts plotter.XYs
+		for i, e := range es {
+			if v, ok := e.Metrics[name]; ok {
+				pts = append(pts, plotter.XY{X: float64(i), Y: v})
+			}
+		}
+		if err := plotutil.AddLinePoints(p, name, pts); err != nil {
+			return err
+		}
+
+		if err := savePlot(p, o, "trend-"+trendSlug(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	gcsEndpoint = "https://storage.googleapis.com"
+	// serves the access token of the default service account on GCE
+	gceTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	// exchanges signed service account assertions for access tokens
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	gcsScope       = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// gcsStorage stores files in a Google Cloud Storage bucket through the
+// JSON API.
+type gcsStorage struct {
+	endpoint string
+	bucket   string
+	prefix   string
+
+	// OAuth2 access token; found by accessToken if empty
+	token string
+	// service account key file, e.g. from GOOGLE_APPLICATION_CREDENTIALS
+	credentials string
+	client      *http.Client
+	now         func() time.Time
+}
+
+func newGCSStorage(bucket, prefix string, cfg storageConfig) *gcsStorage {
+	endpoint := cfg.gcsEndpoint
+	if endpoint == "" {
+		endpoint = gcsEndpoint
+	}
+	return &gcsStorage{
+		endpoint:    endpoint,
+		bucket:      bucket,
+		prefix:      prefix,
+		token:       cfg.gcsToken,
+		credentials: cfg.gcsCredentials,
+		client:      http.DefaultClient,
+		now:         time.Now,
+	}
+}
+
+func (s *gcsStorage) Put(key string, data []byte, contentType string) error {
+	if s.token == "" {
+		t, err := s.accessToken()
+		if err != nil {
+			return fmt.Errorf("cannot get GCS access token: %v", err)
+		}
+		s.token = t
+	}
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		s.endpoint, url.QueryEscape(s.bucket), url.QueryEscape(objectName(s.prefix, key)))
+	req, err := http.NewRequest("POST", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("upload of %s: %s: %s", key, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// URL returns the object in the cloud console, or the destination in its
+// storage browser if key is empty.
+func (s *gcsStorage) URL(key string) string {
+	if key == "" {
+		return "https://console.developers.google.com/storage/browser/" + s.bucket + "/" + s.prefix
+	}
+	return "https://storage.cloud.google.com/" + s.bucket + "/" + objectName(s.prefix, key)
+}
+
+// accessToken finds credentials much as gsutil does: it returns a token
+// of the service account of the key file, or else one printed by gcloud,
+// or else one of the default service account of the GCE instance.
+func (s *gcsStorage) accessToken() (string, error) {
+	if s.credentials != "" {
+		return s.serviceAccountToken()
+	}
+	if _, err := exec.LookPath("gcloud"); err != nil {
+		return s.metadataToken()
+	}
+	t, gerr := gcloudToken()
+	if gerr == nil {
+		return t, nil
+	}
+	t, err := s.metadataToken()
+	if err != nil {
+		return "", fmt.Errorf("%v; %v", gerr, err)
+	}
+	return t, nil
+}
+
+// gcloudToken returns the access token of the account gcloud is logged in
+// with.
+func gcloudToken() (string, error) {
+	out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("gcloud auth print-access-token: %v: %s", err, bytes.TrimSpace(ee.Stderr))
+		}
+		return "", fmt.Errorf("gcloud auth print-access-token: %v", err)
+	}
+	t := strings.TrimSpace(string(out))
+	if t == "" {
+		return "", errors.New("gcloud printed no access token")
+	}
+	return t, nil
+}
+
+// serviceAccountKey is the JSON key file of a service account.
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// serviceAccountToken exchanges an assertion signed by the service account
+// of the key file for an access token, as in
+// https://developers.google.com/identity/protocols/oauth2/service-account.
+func (s *gcsStorage) serviceAccountToken() (string, error) {
+	data, err := ioutil.ReadFile(s.credentials)
+	if err != nil {
+		return "", err
+	}
+	var k serviceAccountKey
+	if err := json.Unmarshal(data, &k); err != nil {
+		return "", fmt.Errorf("%s: %v", s.credentials, err)
+	}
+	if k.ClientEmail == "" || k.PrivateKey == "" {
+		return "", fmt.Errorf("%s is not a service account key", s.credentials)
+	}
+	if k.TokenURI == "" {
+		k.TokenURI = googleTokenURL
+	}
+	jwt, err := k.assertion(s.now())
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", s.credentials, err)
+	}
+
+	resp, err := s.client.PostForm(k.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {jwt},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("token exchange: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.AccessToken == "" {
+		return "", errors.New("token exchange returned no access token")
+	}
+	return t.AccessToken, nil
+}
+
+// assertion returns a JWT signed by the key, valid for an hour from now,
+// asking for read-write access to GCS.
+func (k serviceAccountKey) assertion(now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return "", errors.New("private key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if pk, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rk, ok := pk.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("private key is not an RSA key")
+		}
+		key = rk
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("bad private key: %v", err)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": k.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   k.ClientEmail,
+		"scope": gcsScope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// metadataToken returns the access token of the default service account
+// of the GCE instance we run on.
+func (s *gcsStorage) metadataToken() (string, error) {
+	req, err := http.NewRequest("GET", gceTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server: %s", resp.Status)
+	}
+
+	var t struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.AccessToken == "" {
+		return "", errors.New("metadata server returned no access token")
+	}
+	return t.AccessToken, nil
+}
@@ -0,0 +1,243 @@
+// Command publisher generates the reports of a kubemark density test log
+// with logplot and publishes them, along with the log, to storage. It
+// appends the results and the location of the reports to an env file for
+// the CI job to pick up.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coreos/kscale/logplot"
+)
+
+// config is what to publish and where.
+type config struct {
+	logFile string
+	project string
+	envFile string
+	workDir string
+	dest    string
+
+	// trend store to record the run to, if any
+	record string
+	build  string
+	meta   logplot.Metadata
+
+	// Pushgateway to push the metrics of the run to, if any
+	pushgateway string
+
+	// whether to skip malformed lines of the log instead of failing
+	lenient bool
+
+	storage storageConfig
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.logFile, "log", os.Getenv("KUBEMARK_LOG_FILE"), "kubemark density test log")
+	flag.StringVar(&cfg.project, "project", os.Getenv("KUBEMARK_PROJECT_NAME"),
+		"kubemark project name, which names the directory of the reports")
+	flag.StringVar(&cfg.envFile, "env-file", os.Getenv("OUTPUT_ENV_FILE"), "file to append KEY=value results to")
+	flag.StringVar(&cfg.workDir, "work-dir", os.Getenv("PUBLISH_WORK_DIR"), "directory to generate the reports in")
+	flag.StringVar(&cfg.dest, "dest", defaultDest(),
+		"where to publish: gs://bucket/prefix, s3://bucket/prefix, or a local directory")
+	flag.StringVar(&cfg.storage.s3Endpoint, "s3-endpoint", envOr("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		"endpoint of S3-compatible storage, e.g. http://localhost:9000 for a local MinIO")
+	flag.StringVar(&cfg.storage.s3Region, "s3-region", envOr("AWS_REGION", "us-east-1"), "region of S3-compatible storage")
+	flag.StringVar(&cfg.record, "record", os.Getenv("TREND_DB"), "trend store to append the metrics of the run to")
+	flag.StringVar(&cfg.build, "build", os.Getenv("BUILD_NUMBER"), "build number of the run")
+	flag.StringVar(&cfg.pushgateway, "pushgateway", os.Getenv("PUSHGATEWAY_URL"),
+		"Pushgateway to push the metrics of the run to, e.g. http://localhost:9091")
+	flag.BoolVar(&cfg.lenient, "lenient", false, "skip and count malformed lines of the log instead of failing")
+	clusterSize := flag.String("cluster-size", os.Getenv("NUM_NODES"), "number of nodes of the cluster")
+	gitSHA := flag.String("git-sha", os.Getenv("GIT_SHA"), "git SHA of the build under test")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "S3 credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. A GCS access")
+		fmt.Fprintln(os.Stderr, "token is read from GCS_OAUTH_TOKEN, or got with the service account key file named by")
+		fmt.Fprintln(os.Stderr, "GOOGLE_APPLICATION_CREDENTIALS, or from gcloud, or else from the GCE metadata server.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg.storage.s3AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	cfg.storage.s3SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	cfg.storage.gcsToken = os.Getenv("GCS_OAUTH_TOKEN")
+	cfg.storage.gcsCredentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	cfg.meta = logplot.Metadata{"project": cfg.project}
+	for k, v := range map[string]string{
+		logplot.MetadataClusterSize: *clusterSize,
+		logplot.MetadataGitSHA:      *gitSHA,
+		"build":                     cfg.build,
+	} {
+		if v != "" {
+			cfg.meta[k] = v
+		}
+	}
+
+	if err := publish(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+// defaultDest is where the nightly job has always published to.
+func defaultDest() string {
+	if d := os.Getenv("GCS_DIR"); d != "" {
+		return "gs://" + d
+	}
+	return fmt.Sprintf("gs://metrics-kscale/jenkins/%s/%s", time.Now().Format("2006-01-02"), os.Getenv("BUILD_NUMBER"))
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// publish generates the reports of the log into the project directory of
+// the work directory, uploads the directory and appends the results to
+// the env file.
+func publish(cfg config) error {
+	for _, v := range []struct{ name, value string }{
+		{"log", cfg.logFile}, {"project", cfg.project}, {"env-file", cfg.envFile}, {"work-dir", cfg.workDir},
+	} {
+		if v.value == "" {
+			return fmt.Errorf("-%s is needed", v.name)
+		}
+	}
+	storage, err := newStorage(cfg.dest, cfg.storage)
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(cfg.workDir, cfg.project)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	log, err := ioutil.ReadFile(cfg.logFile)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(outDir, filepath.Base(cfg.logFile)), log, 0644); err != nil {
+		return err
+	}
+
+	rs, err := report(cfg, outDir)
+	if err != nil {
+		return err
+	}
+
+	keys, err := upload(storage, outDir, cfg.project)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("published %d files to %s\n", len(keys), storage.URL(""))
+
+	env := []string{
+		"avg_running_rate=" + strconv.FormatFloat(logplot.AvgRunningRate(rs), 'f', 6, 64),
+		fmt.Sprintf("REPORT_URL=%q", storage.URL(cfg.project+"/"+logplot.HTMLReportName)),
+	}
+	if _, ok := storage.(*gcsStorage); ok {
+		env = append(env, fmt.Sprintf("GCS_DIR=%q", storage.URL("")))
+	}
+	return appendEnv(cfg.envFile, env)
+}
+
+// report runs logplot on the log, writing its reports into outDir, and
+// pushes its metrics to the Pushgateway and records the run to the trend
+// store if asked to.
+func report(cfg config, outDir string) ([]logplot.Record, error) {
+	lt, ok := logplot.Lookup("density")
+	if !ok {
+		return nil, errors.New("logplot does not support density logs")
+	}
+	opts := logplot.DefaultOptions()
+	opts.Output.Dir = outDir
+	opts.Log = os.Stdout
+	opts.Lenient = cfg.lenient
+
+	rs, bad, err := lt.ParseFile(cfg.logFile, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v", err)
+	}
+	if len(bad) > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: skipped %d malformed lines, first at %v\n", len(bad), bad[0])
+	}
+
+	var graphs []string
+	opts.Graphs = &graphs
+	if err := lt.Report(rs, opts); err != nil {
+		return nil, err
+	}
+	run := logplot.Run{Label: cfg.project, Records: rs}
+	if err := lt.WriteHTMLReport([]logplot.Run{run}, cfg.meta, graphs, opts); err != nil {
+		return nil, err
+	}
+
+	if cfg.pushgateway != "" {
+		grouping := map[string]string{"type": "density", "project": cfg.project}
+		if err := logplot.PushPrometheus(cfg.pushgateway, "kscale", grouping, "density", []logplot.Run{run}, nil, cfg.meta); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.record != "" {
+		e, err := logplot.NewTrendEntry("density", cfg.build, rs, cfg.meta)
+		if err != nil {
+			return nil, err
+		}
+		if err := logplot.AppendTrend(cfg.record, e); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// upload stores every file of dir under prefix and returns their keys.
+func upload(s Storage, dir, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key := prefix + "/" + filepath.ToSlash(rel)
+		if err := s.Put(key, data, mime.TypeByExtension(filepath.Ext(path))); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+// appendEnv appends KEY=value lines to the env file at path.
+func appendEnv(path string, lines []string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(f, l); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
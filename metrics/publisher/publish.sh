@@ -1,5 +1,9 @@
 #!/usr/bin/env bash
 
+# Kept for existing jobs; publisher reads the same environment variables.
+# It uploads without gsutil: give GCS credentials as described in README.md,
+# e.g. GOOGLE_APPLICATION_CREDENTIALS or a logged-in gcloud.
+
 set -o errexit
 set -o nounset
 set -o pipefail
@@ -9,30 +13,10 @@ set -o pipefail
 : ${OUTPUT_ENV_FILE:?"Need to set OUTPUT_ENV_FILE"}
 : ${PUBLISH_WORK_DIR:?"Need to set PUBLISH_WORK_DIR"}
 
-upload_to_gcs() {
-  copy_dir=$1
-  bucket_name="metrics-kscale"
-  date_format=$(date +"%Y-%m-%d")
-  GCS_DIR=${GCS_DIR:-"${bucket_name}/jenkins/${date_format}/${BUILD_NUMBER}"}
-
-  echo "GCS_DIR=\"https://console.developers.google.com/storage/browser/${GCS_DIR}\"" >> "${OUTPUT_ENV_FILE}"
-  gsutil cp -r "${copy_dir}" "gs://${GCS_DIR}"
-}
-
-if ! command -v logplot >/dev/null 2>&1; then
-  echo "Please install logplot (github.com/coreos/kscale/logplot)"
+if ! command -v publisher >/dev/null 2>&1; then
+  echo "Please install publisher (github.com/coreos/kscale/metrics/publisher)"
   exit 1
 fi
 
-pushd "${PUBLISH_WORK_DIR}"
-  mkdir -p "${KUBEMARK_PROJECT_NAME}"
-  mv "${KUBEMARK_LOG_FILE}" "${KUBEMARK_PROJECT_NAME}/"
-  pushd "${KUBEMARK_PROJECT_NAME}"
-    log_file=$(basename ${KUBEMARK_LOG_FILE})
-    logplot -f "${log_file}"
-    echo "avg_running_rate=$(cat avg-running-rate.txt)" >> "${OUTPUT_ENV_FILE}"
-    echo "kubemark reports: $(ls *)"
-  popd
-
-  upload_to_gcs "./"
-popd
+# a garbled line should not fail the nightly publish
+exec publisher -lenient "$@"
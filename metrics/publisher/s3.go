@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Storage stores files in a bucket of an S3-compatible service, such as
+// AWS S3, MinIO or Ceph. Buckets are addressed by path, which all of them
+// support.
+type s3Storage struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	prefix   string
+
+	accessKey, secretKey string
+
+	client *http.Client
+	// returns the signing time; only overridden by tests
+	now func() time.Time
+}
+
+func newS3Storage(bucket, prefix string, cfg storageConfig) (*s3Storage, error) {
+	if cfg.s3AccessKey == "" || cfg.s3SecretKey == "" {
+		return nil, errors.New("S3 storage needs an access key and a secret key")
+	}
+	endpoint, err := url.Parse(cfg.s3Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("bad S3 endpoint: %v", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("bad S3 endpoint %q, want e.g. https://s3.amazonaws.com", cfg.s3Endpoint)
+	}
+	return &s3Storage{
+		endpoint:  endpoint,
+		region:    cfg.s3Region,
+		bucket:    bucket,
+		prefix:    prefix,
+		accessKey: cfg.s3AccessKey,
+		secretKey: cfg.s3SecretKey,
+		client:    http.DefaultClient,
+		now:       time.Now,
+	}, nil
+}
+
+func (s *s3Storage) Put(key string, data []byte, contentType string) error {
+	u := s.objectURL(key)
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, data)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("PUT %s: %s: %s", u, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+func (s *s3Storage) URL(key string) string {
+	return s.objectURL(key).String()
+}
+
+func (s *s3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + objectName(s.prefix, key)
+	return &u
+}
+
+// sign signs req with AWS signature version 4, signing the host, the
+// content type and the x-amz-* headers. See
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *s3Storage) sign(req *http.Request, payload []byte) {
+	t := s.now().UTC()
+	date := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		k = strings.ToLower(k)
+		if k == "content-type" || k == "range" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(vs, ","))
+		}
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		date,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
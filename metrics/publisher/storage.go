@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage stores published files under a destination prefix.
+type Storage interface {
+	// Put stores data as the object named key under the destination.
+	Put(key string, data []byte, contentType string) error
+	// URL returns where a person can view the object named key, or the
+	// whole destination if key is empty.
+	URL(key string) string
+}
+
+// storageConfig configures the storage backends that need more than a
+// destination.
+type storageConfig struct {
+	// S3-compatible endpoint, e.g. https://s3.amazonaws.com or a MinIO server
+	s3Endpoint string
+	s3Region   string
+	// credentials of S3-compatible storage
+	s3AccessKey, s3SecretKey string
+
+	// endpoint of the GCS JSON API; only overridden by tests
+	gcsEndpoint string
+	// OAuth2 access token of GCS; if empty, one is found from
+	// gcsCredentials, gcloud or the GCE metadata server
+	gcsToken string
+	// service account key file to get a GCS access token with
+	gcsCredentials string
+}
+
+// newStorage returns the storage of a destination: gs://bucket/prefix for
+// Google Cloud Storage, s3://bucket/prefix for S3-compatible storage, and
+// file:///dir or a plain path for a local directory.
+func newStorage(dest string, cfg storageConfig) (Storage, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "gs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("no bucket in %q", dest)
+		}
+		return newGCSStorage(u.Host, prefix, cfg), nil
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("no bucket in %q", dest)
+		}
+		return newS3Storage(u.Host, prefix, cfg)
+	case "file":
+		return localStorage{dir: u.Path}, nil
+	case "":
+		return localStorage{dir: dest}, nil
+	}
+	return nil, fmt.Errorf("unsupported storage %q in %q", u.Scheme, dest)
+}
+
+// objectName joins a destination prefix and a key.
+func objectName(prefix, key string) string {
+	return path.Join(prefix, key)
+}
+
+// localStorage stores files in a local directory, e.g. one served by a
+// web server or synced elsewhere.
+type localStorage struct {
+	dir string
+}
+
+func (s localStorage) Put(key string, data []byte, contentType string) error {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0644)
+}
+
+func (s localStorage) URL(key string) string {
+	p, err := filepath.Abs(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		p = filepath.Join(s.dir, filepath.FromSlash(key))
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+}